@@ -0,0 +1,63 @@
+package migrate
+
+import (
+	"context"
+	"time"
+)
+
+// AppliedMigration is a migration as recorded in the migrations table.
+type AppliedMigration struct {
+	Name      string    `json:"name"`
+	Hash      string    `json:"hash"`
+	Applied   bool      `json:"applied"`
+	Dirty     bool      `json:"dirty"`
+	AppliedAt time.Time `json:"applied_at,omitzero"`
+	// AppliedBy is the value of WithAppliedBy of the run that applied the
+	// migration, if it was set.
+	AppliedBy string `json:"applied_by,omitempty"`
+	// Duration is how long the migration took to execute when it was last
+	// applied, with millisecond precision. It is zero for migrations applied
+	// before durations were recorded.
+	Duration time.Duration `json:"duration,omitempty"`
+}
+
+// Applied returns every migration recorded in the migrations table, ordered by
+// name, e.g. for an admin dashboard. Unlike Status it does not read the
+// migration files, and like Verify it only reads from the database, not even
+// creating the migrations table.
+func (m *Migrator) Applied() ([]AppliedMigration, error) {
+	timeoutCtx, cancel := context.WithTimeout(context.Background(), m.options.migrationTimeout)
+	defer cancel()
+
+	return m.AppliedContext(timeoutCtx)
+}
+
+// AppliedContext is like Applied but uses ctx for every database call instead
+// of the configured migration timeout.
+func (m *Migrator) AppliedContext(ctx context.Context) ([]AppliedMigration, error) {
+	conn, err := m.conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer m.closeConn(conn)
+
+	knownMigrations, err := m.readMigrationsKnownToDb(conn, ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	applied := make([]AppliedMigration, 0, len(knownMigrations.rows))
+	for _, migration := range knownMigrations.rows {
+		applied = append(applied, AppliedMigration{
+			Name:      migration.MigrationName,
+			Hash:      migration.MigrationHash,
+			Applied:   migration.IsApplied,
+			Dirty:     migration.IsDirty,
+			AppliedAt: migration.AppliedAt,
+			AppliedBy: migration.AppliedBy,
+			Duration:  migration.Duration,
+		})
+	}
+
+	return applied, nil
+}
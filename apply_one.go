@@ -0,0 +1,80 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+)
+
+// ApplyOne applies only the migration matching name, which is matched like
+// the target of MigrateTo, regardless of the migrations before or after it.
+// It runs the same checks on the applied migrations as Migrate and fails with
+// ErrMigrationsAlreadyApplied if the migration is applied already. The
+// migration runs in its own transaction unless it has a
+// "-- migrate:no-transaction" directive. It is meant for debugging and for
+// recovering from a partially failed run, as applying migrations out of
+// order can leave a schema no full run would produce.
+func (m *Migrator) ApplyOne(name string) error {
+	timeoutCtx, cancel := context.WithTimeout(context.Background(), m.options.migrationTimeout)
+	defer cancel()
+
+	return m.ApplyOneContext(timeoutCtx, name)
+}
+
+// ApplyOneContext is like ApplyOne but uses ctx for every database call
+// instead of the configured migration timeout.
+func (m *Migrator) ApplyOneContext(ctx context.Context, name string) error {
+	err := m.VerifyLock()
+	if err != nil {
+		return err
+	}
+
+	paths, err := m.migrationFiles()
+	if err != nil {
+		return err
+	}
+	migrationPath, _, err := m.targetPath(paths, name)
+	if err != nil {
+		return err
+	}
+
+	conn, release, err := m.connectLocked(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	knownMigrations, err := m.getMigrationsKnownToDb(conn, ctx)
+	if err != nil {
+		return err
+	}
+	if hasDirtyMigration(knownMigrations) {
+		return ErrDirtyMigration
+	}
+	if migration, ok := m.findMigration(knownMigrations, migrationPath); ok && migration.IsApplied {
+		return fmt.Errorf("migration %q: %w", migration.MigrationName, ErrMigrationsAlreadyApplied)
+	}
+
+	err = m.checkMigrations(conn, ctx, paths, knownMigrations)
+	if err != nil {
+		return err
+	}
+
+	pending, err := m.pendingMigrations(ctx, []string{migrationPath}, knownMigrations)
+	if err != nil {
+		return err
+	}
+	if len(pending) == 0 {
+		return fmt.Errorf("migration %q does not apply in environment %q", m.migrationFileName(migrationPath), m.options.env)
+	}
+
+	err = m.confirmDestructive(pending)
+	if err != nil {
+		return err
+	}
+
+	migration := pending[0]
+	if migration.isNoTransaction() || !m.transactionalDDL() {
+		return m.executeMigration(conn, ctx, migration)
+	}
+	return m.applyMigrationInTransaction(conn, ctx, migration)
+}
@@ -0,0 +1,93 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+)
+
+// Baseline marks every migration up to and including upTo as applied without
+// executing it, for adopting the Migrator on a database whose schema already
+// exists. upTo matches migrations like the target of MigrateTo. The stored
+// hashes are those of the current files, so later changes are still detected.
+// It fails with ErrMigrationsAlreadyApplied if any migration is recorded as
+// applied, as such a database has been migrated before.
+func (m *Migrator) Baseline(upTo string) error {
+	timeoutCtx, cancel := context.WithTimeout(context.Background(), m.options.migrationTimeout)
+	defer cancel()
+
+	return m.BaselineContext(timeoutCtx, upTo)
+}
+
+// BaselineContext is like Baseline but uses ctx for every database call
+// instead of the configured migration timeout.
+func (m *Migrator) BaselineContext(ctx context.Context, upTo string) error {
+	version, err := m.targetVersion(upTo)
+	if err != nil {
+		return err
+	}
+
+	conn, release, err := m.connectLocked(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	knownMigrations, err := m.getMigrationsKnownToDb(conn, ctx)
+	if err != nil {
+		return err
+	}
+	for _, migration := range knownMigrations.rows {
+		if migration.IsApplied || migration.IsDirty {
+			return fmt.Errorf("baseline: migration %q is already recorded: %w", migration.MigrationName, ErrMigrationsAlreadyApplied)
+		}
+	}
+
+	paths, err := m.migrationFiles()
+	if err != nil {
+		return err
+	}
+
+	pending, err := m.pendingMigrations(ctx, paths, knownMigrations)
+	if err != nil {
+		return err
+	}
+	pending, err = m.pendingUpTo(pending, version)
+	if err != nil {
+		return err
+	}
+
+	// All rows are written in one transaction, so a failing baseline does not
+	// leave the database half adopted.
+	tx, err := conn.BeginTx(ctx, m.txOptions())
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, migration := range pending {
+		migrationHash, err := m.hashMigration(migration.content)
+		if err != nil {
+			return fmt.Errorf("hash migration %q: %w", m.migrationFileName(migration.path), err)
+		}
+
+		err = m.upsertMigration(tx, ctx, migrationRow{
+			MigrationName: migration.name,
+			MigrationHash: migrationHash,
+			IsApplied:     true,
+			IsDirty:       false,
+			Description:   migration.description(),
+			AppliedBy:     m.options.appliedBy,
+		})
+		if err != nil {
+			return err
+		}
+		m.options.logger.InfoContext(ctx, "baselined migration", "migration", migration.name)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("commit baseline: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,66 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// TableInspector is implemented by dialects that can tell whether the
+// migrations table exists, which WithOnBootstrap needs to tell a fresh
+// database from an established one. It also lets the Migrator refuse a table
+// of another tool before altering it.
+type TableInspector interface {
+	// TableExistsQuery returns a query selecting a single boolean, or an
+	// integer that is 1 or 0, telling whether table exists. The table name
+	// may be qualified with a schema.
+	TableExistsQuery(table string) string
+}
+
+func (PostgresDialect) TableExistsQuery(table string) string {
+	return fmt.Sprintf("SELECT to_regclass('%s') IS NOT NULL", table)
+}
+
+func (MySQLDialect) TableExistsQuery(table string) string {
+	database, name, ok := strings.Cut(table, ".")
+	if !ok {
+		return fmt.Sprintf("SELECT COUNT(*) > 0 FROM information_schema.tables WHERE table_schema = DATABASE() AND table_name = '%s'", table)
+	}
+
+	return fmt.Sprintf("SELECT COUNT(*) > 0 FROM information_schema.tables WHERE table_schema = '%s' AND table_name = '%s'", database, name)
+}
+
+func (SQLiteDialect) TableExistsQuery(table string) string {
+	return fmt.Sprintf("SELECT COUNT(*) > 0 FROM sqlite_master WHERE type = 'table' AND name = '%s'", table)
+}
+
+// tableExists reports whether the migrations table exists.
+func (m *Migrator) tableExists(db querier, ctx context.Context) (bool, error) {
+	inspector, ok := m.dialect().(TableInspector)
+	if !ok {
+		return false, fmt.Errorf("inspect migrations table: dialect %T cannot tell whether a table exists", m.dialect())
+	}
+
+	rows, err := db.QueryContext(ctx, inspector.TableExistsQuery(m.table()))
+	if err != nil {
+		return false, fmt.Errorf("inspect migrations table %q: %w", m.table(), err)
+	}
+	defer rows.Close()
+
+	var exists bool
+	if rows.Next() {
+		err = rows.Scan(&exists)
+		if err != nil {
+			return false, fmt.Errorf("inspect migrations table %q: %w", m.table(), err)
+		}
+	}
+
+	return exists, rows.Err()
+}
+
+// bootstrapped reports the creation of the migrations table.
+func (m *Migrator) bootstrapped(ctx context.Context) error {
+	m.options.logger.InfoContext(ctx, "created migrations table", "table", m.table())
+
+	return callHook("bootstrap", func() { m.options.onBootstrap(m.table()) })
+}
@@ -0,0 +1,172 @@
+// Command migrate applies and inspects the SQL migrations in a directory,
+// for running migrations outside of the application, e.g.
+//
+//	DATABASE_URL=postgres://localhost/app go run ./cmd/migrate -dir migrations up
+//
+// The subcommands are up, which applies all pending migrations, down, which
+// rolls back the latest applied ones, status, which lists every migration and
+// its state, and verify, which checks the applied migrations against their
+// files. -driver sqlite runs them on the SQLite database file DATABASE_URL
+// names instead of PostgreSQL.
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"text/tabwriter"
+	"time"
+
+	_ "github.com/lib/pq"  // PostgreSQL driver
+	_ "modernc.org/sqlite" // SQLite driver
+
+	migrate "github.com/theonewiththewrench/go-migrate"
+)
+
+func main() {
+	err := run(os.Args[1:], os.Stdout)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "migrate:", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string, stdout io.Writer) error {
+	var (
+		flags   = flag.NewFlagSet("migrate", flag.ContinueOnError)
+		dir     = flags.String("dir", "migrations", "directory holding the migration files")
+		table   = flags.String("table", "migrations", "name of the migrations table")
+		driver  = flags.String("driver", "postgres", "database driver, postgres or sqlite")
+		steps   = flags.Int("steps", 1, "number of migrations down rolls back")
+		timeout = flags.Duration("timeout", 10*time.Second, "maximum time for the whole command")
+	)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: migrate [flags] up|down|status|verify")
+		flags.PrintDefaults()
+	}
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return errors.New("expected exactly one subcommand")
+	}
+	if *driver != "postgres" && *driver != "sqlite" {
+		return fmt.Errorf("unknown driver %q", *driver)
+	}
+
+	databaseURL := os.Getenv("DATABASE_URL")
+	if databaseURL == "" {
+		return errors.New("DATABASE_URL is not set")
+	}
+
+	// The timeout bounds the whole command, from connecting to the last
+	// statement, rather than every call to the Migrator on its own.
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	db, err := sql.Open(*driver, databaseURL)
+	if err != nil {
+		return fmt.Errorf("open database: %w", err)
+	}
+	defer db.Close()
+
+	migrator := migrate.NewMigrator(db, os.DirFS(*dir), migrate.WithTableName(*table))
+
+	switch command := flags.Arg(0); command {
+	case "up":
+		return up(ctx, migrator, stdout)
+	case "down":
+		return down(ctx, migrator, *steps, stdout)
+	case "status":
+		return status(ctx, migrator, stdout)
+	case "verify":
+		return verify(ctx, migrator, stdout)
+	default:
+		flags.Usage()
+		return fmt.Errorf("unknown subcommand %q", command)
+	}
+}
+
+func up(ctx context.Context, migrator *migrate.Migrator, stdout io.Writer) error {
+	result, err := migrator.MigrateResultContext(ctx)
+	for _, name := range result.Applied {
+		fmt.Fprintln(stdout, "applied", name)
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(stdout, "applied %d migrations, latest version %d\n", result.Count, result.LatestVersion)
+	return nil
+}
+
+// down prints the migrations it rolled back, which are fewer than steps if
+// fewer are applied.
+func down(ctx context.Context, migrator *migrate.Migrator, steps int, stdout io.Writer) error {
+	before, err := migrator.AppliedContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = migrator.RollbackContext(ctx, steps)
+	if err != nil {
+		return err
+	}
+
+	after, err := migrator.AppliedContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	stillApplied := make(map[string]bool, len(after))
+	for _, migration := range after {
+		stillApplied[migration.Name] = migration.Applied
+	}
+
+	var count int
+	for _, migration := range slices.Backward(before) {
+		if migration.Applied && !stillApplied[migration.Name] {
+			fmt.Fprintln(stdout, "rolled back", migration.Name)
+			count++
+		}
+	}
+
+	fmt.Fprintf(stdout, "rolled back %d migrations\n", count)
+	return nil
+}
+
+func status(ctx context.Context, migrator *migrate.Migrator, stdout io.Writer) error {
+	statuses, err := migrator.StatusContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tSTATE\tDIRTY\tAPPLIED AT\tDESCRIPTION")
+	for _, s := range statuses {
+		appliedAt := ""
+		if !s.AppliedAt.IsZero() {
+			appliedAt = s.AppliedAt.Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%t\t%s\t%s\n", s.Name, s.State, s.Dirty, appliedAt, s.Description)
+	}
+
+	return w.Flush()
+}
+
+func verify(ctx context.Context, migrator *migrate.Migrator, stdout io.Writer) error {
+	err := migrator.VerifyContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(stdout, "all applied migrations match their files")
+	return nil
+}
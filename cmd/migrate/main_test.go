@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// setupMigrations writes two migrations with down migrations to a directory
+// and points DATABASE_URL at an empty SQLite database file. It returns the
+// directory.
+func setupMigrations(t *testing.T) string {
+	var (
+		dir   = t.TempDir()
+		files = map[string]string{
+			"001_users.sql":       "-- migrate:description Add the users table\nCREATE TABLE users (id INT PRIMARY KEY);",
+			"001_users.down.sql":  "DROP TABLE users;",
+			"002_orders.sql":      "CREATE TABLE orders (id INT PRIMARY KEY);",
+			"002_orders.down.sql": "DROP TABLE orders;",
+		}
+	)
+	for name, content := range files {
+		err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600)
+		assert.NoError(t, err)
+	}
+	t.Setenv("DATABASE_URL", "file:"+filepath.Join(t.TempDir(), "test.db"))
+
+	return dir
+}
+
+func TestRun(t *testing.T) {
+	t.Run("up", func(t *testing.T) {
+		t.Run("applies the pending migrations", func(t *testing.T) {
+			// Arrange
+			var (
+				dir    = setupMigrations(t)
+				stdout bytes.Buffer
+			)
+
+			// Act
+			err := run([]string{"-driver", "sqlite", "-dir", dir, "up"}, &stdout)
+
+			// Assert
+			assert.NoError(t, err)
+			assert.Equal(t, "applied 001_users.sql\napplied 002_orders.sql\napplied 2 migrations, latest version 2\n", stdout.String())
+		})
+
+		t.Run("applies nothing when nothing is pending", func(t *testing.T) {
+			// Arrange
+			var (
+				dir    = setupMigrations(t)
+				stdout bytes.Buffer
+			)
+			err := run([]string{"-driver", "sqlite", "-dir", dir, "up"}, &bytes.Buffer{})
+			assert.NoError(t, err)
+
+			// Act
+			err = run([]string{"-driver", "sqlite", "-dir", dir, "up"}, &stdout)
+
+			// Assert
+			assert.NoError(t, err)
+			assert.Equal(t, "applied 0 migrations, latest version 2\n", stdout.String())
+		})
+
+		t.Run("bounds the whole command by the timeout", func(t *testing.T) {
+			// Arrange
+			var (
+				dir = setupMigrations(t)
+			)
+
+			// Act
+			err := run([]string{"-driver", "sqlite", "-dir", dir, "-timeout", "1ns", "up"}, &bytes.Buffer{})
+
+			// Assert
+			assert.ErrorIs(t, err, context.DeadlineExceeded)
+		})
+	})
+
+	t.Run("down", func(t *testing.T) {
+		t.Run("rolls back the latest migration", func(t *testing.T) {
+			// Arrange
+			var (
+				dir    = setupMigrations(t)
+				stdout bytes.Buffer
+				status bytes.Buffer
+			)
+			err := run([]string{"-driver", "sqlite", "-dir", dir, "up"}, &bytes.Buffer{})
+			assert.NoError(t, err)
+
+			// Act
+			err = run([]string{"-driver", "sqlite", "-dir", dir, "down"}, &stdout)
+
+			// Assert
+			assert.NoError(t, err)
+			assert.Equal(t, "rolled back 002_orders.sql\nrolled back 1 migrations\n", stdout.String())
+			err = run([]string{"-driver", "sqlite", "-dir", dir, "status"}, &status)
+			assert.NoError(t, err)
+			assert.Regexp(t, `002_orders\.sql\s+pending`, status.String())
+		})
+
+		t.Run("rolls back as many migrations as are applied", func(t *testing.T) {
+			// Arrange
+			var (
+				dir    = setupMigrations(t)
+				stdout bytes.Buffer
+			)
+			err := run([]string{"-driver", "sqlite", "-dir", dir, "up"}, &bytes.Buffer{})
+			assert.NoError(t, err)
+
+			// Act
+			err = run([]string{"-driver", "sqlite", "-dir", dir, "-steps", "5", "down"}, &stdout)
+
+			// Assert
+			assert.NoError(t, err)
+			assert.Equal(t, "rolled back 002_orders.sql\nrolled back 001_users.sql\nrolled back 2 migrations\n", stdout.String())
+		})
+	})
+
+	t.Run("status", func(t *testing.T) {
+		t.Run("lists every migration with its state", func(t *testing.T) {
+			// Arrange
+			var (
+				dir    = setupMigrations(t)
+				stdout bytes.Buffer
+			)
+			err := run([]string{"-driver", "sqlite", "-dir", dir, "up"}, &bytes.Buffer{})
+			assert.NoError(t, err)
+
+			// Act
+			err = run([]string{"-driver", "sqlite", "-dir", dir, "status"}, &stdout)
+
+			// Assert
+			assert.NoError(t, err)
+			assert.Regexp(t, `^NAME\s+STATE\s+DIRTY\s+APPLIED AT\s+DESCRIPTION\n`, stdout.String())
+			assert.Regexp(t, `001_users\.sql\s+applied\s+false\s+\S+\s+Add the users table\n`, stdout.String())
+			assert.Regexp(t, `002_orders\.sql\s+applied\s+false\s+\S+\s*\n`, stdout.String())
+		})
+	})
+
+	t.Run("verify", func(t *testing.T) {
+		t.Run("succeeds when the applied migrations match their files", func(t *testing.T) {
+			// Arrange
+			var (
+				dir    = setupMigrations(t)
+				stdout bytes.Buffer
+			)
+			err := run([]string{"-driver", "sqlite", "-dir", dir, "up"}, &bytes.Buffer{})
+			assert.NoError(t, err)
+
+			// Act
+			err = run([]string{"-driver", "sqlite", "-dir", dir, "verify"}, &stdout)
+
+			// Assert
+			assert.NoError(t, err)
+			assert.Equal(t, "all applied migrations match their files\n", stdout.String())
+		})
+	})
+
+	t.Run("should error for an unknown subcommand", func(t *testing.T) {
+		// Arrange
+		var (
+			dir = setupMigrations(t)
+		)
+
+		// Act
+		err := run([]string{"-driver", "sqlite", "-dir", dir, "sideways"}, &bytes.Buffer{})
+
+		// Assert
+		assert.ErrorContains(t, err, `unknown subcommand "sideways"`)
+	})
+}
@@ -0,0 +1,227 @@
+package migrate
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"strings"
+)
+
+const (
+	dataFileDirective = "data-file"
+
+	// dataFileBatchSize is the number of rows inserted per statement when
+	// loading a data file.
+	dataFileBatchSize = 500
+	// maxQueryParameters is the maximum number of parameters PostgreSQL and
+	// MySQL accept in a single statement.
+	maxQueryParameters = 65535
+)
+
+// dataFile is a CSV file referenced by a migration through a
+// "-- migrate:data-file <file> <table>" directive. Its rows are inserted into
+// table after the migration itself has been executed.
+type dataFile struct {
+	path  string
+	table string
+}
+
+func parseDataFiles(directives []directive) ([]dataFile, error) {
+	var dataFiles []dataFile
+	for _, directive := range directives {
+		if directive.name != dataFileDirective {
+			continue
+		}
+
+		if len(directive.args) != 2 {
+			return nil, fmt.Errorf("%s directive needs a file and a table, got %q", dataFileDirective, strings.Join(directive.args, " "))
+		}
+
+		file, table := directive.args[0], directive.args[1]
+		if !isQualifiedIdentifier(table) {
+			return nil, fmt.Errorf("%s directive: invalid table name %q", dataFileDirective, table)
+		}
+
+		dataFiles = append(dataFiles, dataFile{path: file, table: table})
+	}
+
+	return dataFiles, nil
+}
+
+// hashMigration returns the hash stored for a migration. For migrations that
+// load data files, the content of those files is part of the hash so changing
+// a data file is detected like changing the migration itself.
+func (m *Migrator) hashMigration(content []byte) (string, error) {
+	return m.hashMigrationWith(content, m.options.hasher)
+}
+
+// hashMatches returns the current hash of content and reports whether
+// storedHash is its hash, either by the configured hasher or by the legacy
+// scheme.
+func (m *Migrator) hashMatches(content []byte, storedHash string) (string, bool, error) {
+	migrationHash, err := m.hashMigration(content)
+	if err != nil {
+		return "", false, err
+	}
+	if migrationHash == storedHash {
+		return migrationHash, true, nil
+	}
+
+	legacyMigrationHash, err := m.hashMigrationWith(content, legacyHash)
+	if err != nil {
+		return "", false, err
+	}
+
+	return migrationHash, legacyMigrationHash == storedHash, nil
+}
+
+func (m *Migrator) hashMigrationWith(content []byte, hasher func([]byte) string) (string, error) {
+	if m.options.normalizeLineEndings {
+		content = bytes.ReplaceAll(content, []byte("\r"), nil)
+	}
+	if m.options.normalizeContent {
+		content = normalizeContent(content)
+	}
+
+	dataFiles, err := parseDataFiles(parseDirectives(content))
+	if err != nil {
+		return "", err
+	}
+	if len(dataFiles) == 0 {
+		return hasher(content), nil
+	}
+
+	combined := append([]byte(nil), content...)
+	for _, dataFile := range dataFiles {
+		dataHash, err := m.hashDataFile(dataFile)
+		if err != nil {
+			return "", err
+		}
+		combined = append(combined, dataHash...)
+	}
+
+	return hasher(combined), nil
+}
+
+func (m *Migrator) hashDataFile(dataFile dataFile) (string, error) {
+	file, err := m.openDataFile(dataFile)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	sha := sha256.New()
+	_, err = io.Copy(sha, file)
+	if err != nil {
+		return "", fmt.Errorf("read data file %q: %w", dataFile.path, err)
+	}
+
+	return fmt.Sprintf("%x", sha.Sum(nil)), nil
+}
+
+func (m *Migrator) openDataFile(dataFile dataFile) (fs.File, error) {
+	if m.options.dataFS == nil {
+		return nil, fmt.Errorf("data file %q: no data filesystem configured, use WithDataFS", dataFile.path)
+	}
+
+	file, err := m.options.dataFS.Open(dataFile.path)
+	if err != nil {
+		return nil, fmt.Errorf("open data file %q: %w", dataFile.path, err)
+	}
+
+	return file, nil
+}
+
+// loadDataFile streams the rows of a CSV data file into its table using
+// batched inserts. The first row holds the column names. Empty values are
+// inserted as NULL.
+func (m *Migrator) loadDataFile(db execer, ctx context.Context, dataFile dataFile) error {
+	file, err := m.openDataFile(dataFile)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.ReuseRecord = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return fmt.Errorf("read header of data file %q: %w", dataFile.path, err)
+	}
+
+	columns := make([]string, len(header))
+	for i, column := range header {
+		column = strings.TrimSpace(column)
+		if !isIdentifier(column) {
+			return fmt.Errorf("data file %q: invalid column name %q", dataFile.path, column)
+		}
+		columns[i] = column
+	}
+
+	batchSize := min(dataFileBatchSize, maxQueryParameters/len(columns))
+	batch := make([]any, 0, batchSize*len(columns))
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("read data file %q: %w", dataFile.path, err)
+		}
+
+		for _, value := range record {
+			if value == "" {
+				batch = append(batch, nil)
+				continue
+			}
+			batch = append(batch, value)
+		}
+
+		if len(batch) == cap(batch) {
+			err = m.insertRows(db, ctx, dataFile.table, columns, batch)
+			if err != nil {
+				return fmt.Errorf("load data file %q: %w", dataFile.path, err)
+			}
+			batch = batch[:0]
+		}
+	}
+
+	if len(batch) > 0 {
+		err = m.insertRows(db, ctx, dataFile.table, columns, batch)
+		if err != nil {
+			return fmt.Errorf("load data file %q: %w", dataFile.path, err)
+		}
+	}
+
+	return nil
+}
+
+func (m *Migrator) insertRows(db execer, ctx context.Context, table string, columns []string, values []any) error {
+	var query strings.Builder
+	fmt.Fprintf(&query, "INSERT INTO %s (%s) VALUES ", table, strings.Join(columns, ", "))
+	for i := range values {
+		switch {
+		case i == 0:
+			query.WriteString("(")
+		case i%len(columns) == 0:
+			query.WriteString("), (")
+		default:
+			query.WriteString(", ")
+		}
+		query.WriteString(m.dialect().Placeholder(i + 1))
+	}
+	query.WriteString(")")
+
+	_, err := db.ExecContext(ctx, query.String(), values...)
+	if err != nil {
+		return fmt.Errorf("insert into %s: %w", table, err)
+	}
+
+	return nil
+}
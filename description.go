@@ -0,0 +1,22 @@
+package migrate
+
+import "strings"
+
+// descriptionDirective describes what a migration does. The description is
+// stored with the migration and reported by Status, e.g.
+//
+//	-- migrate:description Add the users table
+const descriptionDirective = "description"
+
+func (p pendingMigration) description() string {
+	return migrationDescription(p.directives)
+}
+
+func migrationDescription(directives []directive) string {
+	for _, d := range directives {
+		if d.name == descriptionDirective {
+			return strings.Join(d.args, " ")
+		}
+	}
+	return ""
+}
@@ -0,0 +1,51 @@
+package migrate
+
+import (
+	"fmt"
+	"slices"
+)
+
+// destructiveDirective marks a migration that destroys data, such as one that
+// drops a table, so it only runs once the WithConfirm callback approves it,
+// e.g.
+//
+//	-- migrate:destructive
+const destructiveDirective = "destructive"
+
+func (p pendingMigration) isDestructive() bool {
+	return slices.ContainsFunc(p.directives, func(d directive) bool {
+		return d.name == destructiveDirective
+	})
+}
+
+// confirmDestructive asks the WithConfirm callback to approve every
+// destructive pending migration. It runs before any migration is applied, so
+// a declined migration leaves the whole run unapplied.
+func (m *Migrator) confirmDestructive(pending []pendingMigration) error {
+	if m.options.confirm == nil {
+		return nil
+	}
+
+	for _, migration := range pending {
+		if !migration.isDestructive() {
+			continue
+		}
+
+		var (
+			confirmed  bool
+			confirmErr error
+		)
+		err := callHook("confirm", func() { confirmed, confirmErr = m.options.confirm(migration.name) })
+		if err == nil {
+			err = confirmErr
+		}
+		if err != nil {
+			return fmt.Errorf("confirm migration %q: %w", migration.name, err)
+		}
+		if !confirmed {
+			return fmt.Errorf("migration %q: %w", migration.name, ErrMigrationNotConfirmed)
+		}
+	}
+
+	return nil
+}
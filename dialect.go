@@ -0,0 +1,210 @@
+package migrate
+
+import (
+	"database/sql/driver"
+	_ "embed"
+	"fmt"
+	"strings"
+)
+
+// migrationTableQuery upgrades PostgreSQL migrations tables created by older
+// versions.
+//
+//go:embed migration_table_query.sql
+var migrationTableQuery string
+
+// Dialect produces the SQL the Migrator uses to track migrations, so the
+// migrations table can be managed on different databases. Table names passed
+// to a Dialect have already been validated as plain identifiers.
+type Dialect interface {
+	// CreateTableQuery returns the statement that creates the migrations table
+	// if it does not exist yet.
+	CreateTableQuery(table string) string
+	// UpsertQuery returns the statement that inserts or updates a migration.
+	// It takes the migration name, hash, applied flag, dirty flag, applied
+	// time, description, applied by and duration in milliseconds as
+	// parameters, in that order, and must overwrite every one of them.
+	UpsertQuery(table string) string
+	// SelectQuery returns the statement that selects the migration name, hash,
+	// applied flag, dirty flag, applied time, description, applied by and
+	// duration in milliseconds of every migration, in that order. The provided
+	// dialects order the rows by migration name.
+	SelectQuery(table string) string
+	// Placeholder returns the placeholder for the n-th query parameter,
+	// counting from one.
+	Placeholder(n int) string
+}
+
+// ColumnTypes are the SQL types of the columns of the migrations table, so a
+// Dialect can create it with the types its database prefers. A custom Dialect
+// can embed a provided one and change some of its ColumnTypes:
+//
+//	func (d tinyIntDialect) CreateTableQuery(table string) string {
+//		types := d.MySQLDialect.ColumnTypes()
+//		types.Bool = "TINYINT(1)"
+//		return types.CreateTableQuery(table)
+//	}
+type ColumnTypes struct {
+	// Name is the type of migration_name, the primary key.
+	Name string
+	// Text is the type of migration_hash, description and applied_by.
+	Text string
+	// Bool is the type of is_applied and is_dirty.
+	Bool string
+	// Timestamp is the type of applied_at, which must accept NULL.
+	Timestamp string
+}
+
+// CreateTableQuery returns the statement that creates the migrations table
+// with these column types if it does not exist yet. duration_ms is a BIGINT,
+// which every supported database understands.
+func (c ColumnTypes) CreateTableQuery(table string) string {
+	return fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+    migration_name  %s NOT NULL,
+    migration_hash  %s,
+    is_applied      %s NOT NULL DEFAULT FALSE,
+    is_dirty        %s NOT NULL DEFAULT FALSE,
+    applied_at      %s,
+    description     %s,
+    applied_by      %s,
+    duration_ms     BIGINT NOT NULL DEFAULT 0,
+    PRIMARY KEY (migration_name)
+)`, table, c.Name, c.Text, c.Bool, c.Bool, c.Timestamp, c.Text, c.Text)
+}
+
+// PostgresDialect is the Dialect for PostgreSQL, used with drivers such as
+// github.com/lib/pq or github.com/jackc/pgx/v5/stdlib.
+type PostgresDialect struct{}
+
+// CreateTableQuery also upgrades tables created by older versions in place.
+func (d PostgresDialect) CreateTableQuery(table string) string {
+	return d.ColumnTypes().CreateTableQuery(table) + ";\n\n" + fmt.Sprintf(migrationTableQuery, table)
+}
+
+func (PostgresDialect) ColumnTypes() ColumnTypes {
+	return ColumnTypes{Name: "VARCHAR(255)", Text: "TEXT", Bool: "BOOLEAN", Timestamp: "TIMESTAMP"}
+}
+
+func (PostgresDialect) UpsertQuery(table string) string {
+	return fmt.Sprintf(`INSERT INTO %s (migration_name, migration_hash, is_applied, is_dirty, applied_at, description, applied_by, duration_ms)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+			ON CONFLICT(migration_name) DO UPDATE SET
+			migration_hash = excluded.migration_hash,
+			is_applied = excluded.is_applied,
+			is_dirty = excluded.is_dirty,
+			applied_at = excluded.applied_at,
+			description = excluded.description,
+			applied_by = excluded.applied_by,
+			duration_ms = excluded.duration_ms`, table)
+}
+
+func (PostgresDialect) SelectQuery(table string) string {
+	return selectQuery(table)
+}
+
+func (PostgresDialect) Placeholder(n int) string {
+	return fmt.Sprintf("$%d", n)
+}
+
+// MySQLDialect is the Dialect for MySQL and MariaDB, used with drivers such as
+// github.com/go-sql-driver/mysql.
+type MySQLDialect struct{}
+
+func (d MySQLDialect) CreateTableQuery(table string) string {
+	return d.ColumnTypes().CreateTableQuery(table)
+}
+
+func (MySQLDialect) ColumnTypes() ColumnTypes {
+	return ColumnTypes{Name: "VARCHAR(255)", Text: "TEXT", Bool: "BOOLEAN", Timestamp: "DATETIME NULL"}
+}
+
+func (MySQLDialect) UpsertQuery(table string) string {
+	return fmt.Sprintf(`INSERT INTO %s (migration_name, migration_hash, is_applied, is_dirty, applied_at, description, applied_by, duration_ms)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+			ON DUPLICATE KEY UPDATE
+			migration_hash = VALUES(migration_hash),
+			is_applied = VALUES(is_applied),
+			is_dirty = VALUES(is_dirty),
+			applied_at = VALUES(applied_at),
+			description = VALUES(description),
+			applied_by = VALUES(applied_by),
+			duration_ms = VALUES(duration_ms)`, table)
+}
+
+func (MySQLDialect) SelectQuery(table string) string {
+	return selectQuery(table)
+}
+
+func (MySQLDialect) Placeholder(int) string {
+	return "?"
+}
+
+// SQLiteDialect is the Dialect for SQLite 3.24 or newer, used with drivers
+// such as modernc.org/sqlite or github.com/mattn/go-sqlite3.
+type SQLiteDialect struct{}
+
+func (d SQLiteDialect) CreateTableQuery(table string) string {
+	return d.ColumnTypes().CreateTableQuery(table)
+}
+
+func (SQLiteDialect) ColumnTypes() ColumnTypes {
+	return ColumnTypes{Name: "TEXT", Text: "TEXT", Bool: "BOOLEAN", Timestamp: "TIMESTAMP"}
+}
+
+func (SQLiteDialect) UpsertQuery(table string) string {
+	return fmt.Sprintf(`INSERT INTO %s (migration_name, migration_hash, is_applied, is_dirty, applied_at, description, applied_by, duration_ms)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT(migration_name) DO UPDATE SET
+			migration_hash = excluded.migration_hash,
+			is_applied = excluded.is_applied,
+			is_dirty = excluded.is_dirty,
+			applied_at = excluded.applied_at,
+			description = excluded.description,
+			applied_by = excluded.applied_by,
+			duration_ms = excluded.duration_ms`, table)
+}
+
+func (SQLiteDialect) SelectQuery(table string) string {
+	return selectQuery(table)
+}
+
+func (SQLiteDialect) Placeholder(int) string {
+	return "?"
+}
+
+func selectQuery(table string) string {
+	return fmt.Sprintf("SELECT %s FROM %s ORDER BY migration_name", strings.Join(migrationColumns, ", "), table)
+}
+
+// TableDDL returns the statement the Migrator runs to create the migrations
+// table, so the table can be inspected or created up front, e.g. by a DBA. It
+// is the statement set with WithTableDDL, if any.
+func (m *Migrator) TableDDL() string {
+	if m.options.tableDDL != "" {
+		return m.options.tableDDL
+	}
+
+	return m.dialect().CreateTableQuery(m.table())
+}
+
+// dialect returns the configured Dialect, or detects it from the database
+// driver when none is configured. Unknown drivers default to PostgreSQL.
+func (m *Migrator) dialect() Dialect {
+	if m.options.dialect != nil {
+		return m.options.dialect
+	}
+
+	return detectDialect(m.db.Driver())
+}
+
+func detectDialect(d driver.Driver) Dialect {
+	driverType := strings.ToLower(fmt.Sprintf("%T", d))
+	switch {
+	case strings.Contains(driverType, "mysql"):
+		return MySQLDialect{}
+	case strings.Contains(driverType, "sqlite"):
+		return SQLiteDialect{}
+	default:
+		return PostgresDialect{}
+	}
+}
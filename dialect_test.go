@@ -0,0 +1,271 @@
+package migrate_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io/fs"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/theonewiththewrench/go-migrate"
+)
+
+// recordingExecer records the statements it executes instead of sending them
+// to a database, failing those that contain fail.
+type recordingExecer struct {
+	statements []string
+	fail       string
+}
+
+func (e *recordingExecer) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	if e.fail != "" && strings.Contains(query, e.fail) {
+		return nil, errors.New("statement failed")
+	}
+	e.statements = append(e.statements, query)
+	return driver.RowsAffected(0), nil
+}
+
+// assertAppliesMultiStatementMigration migrates the test database with
+// dialect and rolls it back again, checking that every statement of the multi
+// statement fixture and of its down migration ran.
+func assertAppliesMultiStatementMigration(t *testing.T, dialect migrate.Dialect) {
+	var (
+		db       = migrate.SetupTestDatabase(t)
+		migrator = migrate.NewMigrator(db, multiStatementMigration, migrate.WithDialect(dialect))
+		count    int
+	)
+
+	err := migrator.Migrate()
+
+	assert.NoError(t, err)
+	err = db.QueryRow("SELECT COUNT(*) FROM test").Scan(&count)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+
+	err = migrator.Rollback(1)
+
+	assert.NoError(t, err)
+	_, err = db.Exec("SELECT id FROM test")
+	assert.Error(t, err)
+}
+
+func TestColumnTypes(t *testing.T) {
+	t.Run("creates the table with the given types", func(t *testing.T) {
+		var sut = migrate.MySQLDialect{}.ColumnTypes()
+		sut.Bool = "TINYINT(1)"
+
+		query := sut.CreateTableQuery("schema_history")
+
+		assert.Contains(t, query, "CREATE TABLE IF NOT EXISTS schema_history")
+		assert.Contains(t, query, "is_applied      TINYINT(1) NOT NULL DEFAULT FALSE,")
+		assert.Contains(t, query, "is_dirty        TINYINT(1) NOT NULL DEFAULT FALSE,")
+	})
+}
+
+func TestTableDDL(t *testing.T) {
+	t.Run("qualifies the table with the table schema", func(t *testing.T) {
+		var sut = migrate.NewMigrator(nil, nil, migrate.WithDialect(migrate.PostgresDialect{}), migrate.WithTableSchema("_migrations"))
+
+		assert.Contains(t, sut.TableDDL(), "CREATE TABLE IF NOT EXISTS _migrations.migrations")
+	})
+
+	t.Run("returns the configured DDL", func(t *testing.T) {
+		var sut = migrate.NewMigrator(nil, nil, migrate.WithDialect(migrate.PostgresDialect{}), migrate.WithTableDDL("CREATE TABLE custom"))
+
+		assert.Equal(t, "CREATE TABLE custom", sut.TableDDL())
+	})
+}
+
+func TestDialect(t *testing.T) {
+	t.Run("Postgres", func(t *testing.T) {
+		t.Run("uses numbered placeholders", func(t *testing.T) {
+			var sut = migrate.PostgresDialect{}
+
+			assert.Equal(t, "$3", sut.Placeholder(3))
+			assert.Contains(t, sut.UpsertQuery("schema_history"), "ON CONFLICT(migration_name)")
+		})
+
+		t.Run("creates the named table", func(t *testing.T) {
+			var sut = migrate.PostgresDialect{}
+
+			assert.Contains(t, sut.CreateTableQuery("schema_history"), "CREATE TABLE IF NOT EXISTS schema_history")
+		})
+
+		t.Run("only alters tables that need an upgrade", func(t *testing.T) {
+			var sut = migrate.PostgresDialect{}
+
+			assert.NotContains(t, sut.CreateTableQuery("schema_history"), "ADD COLUMN IF NOT EXISTS")
+			assert.Contains(t, sut.CreateTableQuery("schema_history"), "IF NOT EXISTS (\n        SELECT 1 FROM pg_attribute")
+		})
+
+		t.Run("applies a migration file with several statements", func(t *testing.T) {
+			if _, ok := testDialect().(migrate.PostgresDialect); !ok {
+				t.Skip("test database is not PostgreSQL")
+			}
+
+			assertAppliesMultiStatementMigration(t, migrate.PostgresDialect{})
+		})
+	})
+
+	t.Run("MySQL", func(t *testing.T) {
+		t.Run("uses question mark placeholders", func(t *testing.T) {
+			var sut = migrate.MySQLDialect{}
+
+			assert.Equal(t, "?", sut.Placeholder(3))
+			assert.NotContains(t, sut.UpsertQuery("schema_history"), "$")
+			assert.Contains(t, sut.UpsertQuery("schema_history"), "ON DUPLICATE KEY UPDATE")
+		})
+
+		t.Run("creates the named table", func(t *testing.T) {
+			var sut = migrate.MySQLDialect{}
+
+			assert.Contains(t, sut.CreateTableQuery("schema_history"), "CREATE TABLE IF NOT EXISTS schema_history")
+		})
+
+		t.Run("uses its column types", func(t *testing.T) {
+			var sut = migrate.MySQLDialect{}
+
+			assert.Contains(t, sut.CreateTableQuery("schema_history"), "applied_at      DATETIME NULL,")
+		})
+
+		t.Run("reports that DDL cannot be rolled back", func(t *testing.T) {
+			var sut = migrate.MySQLDialect{}
+
+			assert.False(t, sut.TransactionalDDL())
+		})
+
+		t.Run("executes a migration file with several statements one at a time", func(t *testing.T) {
+			var (
+				sut       = migrate.MySQLDialect{}
+				execer    = &recordingExecer{}
+				body, err = fs.ReadFile(multiStatementMigration, "test_data/multi_statement_migration/001_multi_statement.sql")
+			)
+			assert.NoError(t, err)
+
+			err = sut.ExecBody(context.Background(), execer, string(body))
+
+			assert.NoError(t, err)
+			assert.Len(t, execer.statements, 3)
+			assert.Equal(t, "INSERT INTO test (id, name) VALUES (2, 'bob')", execer.statements[2])
+		})
+
+		t.Run("executes a down migration with several statements one at a time", func(t *testing.T) {
+			var (
+				sut       = migrate.MySQLDialect{}
+				execer    = &recordingExecer{}
+				body, err = fs.ReadFile(multiStatementMigration, "test_data/multi_statement_migration/001_multi_statement.down.sql")
+			)
+			assert.NoError(t, err)
+
+			err = sut.ExecBody(context.Background(), execer, string(body))
+
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"DELETE FROM test WHERE id = 2", "DELETE FROM test WHERE id = 1", "DROP TABLE test"}, execer.statements)
+		})
+
+		t.Run("keeps compound statements between delimiter lines together", func(t *testing.T) {
+			var (
+				sut    = migrate.MySQLDialect{}
+				execer = &recordingExecer{}
+				body   = "CREATE TABLE users (name TEXT);\n" +
+					"DELIMITER //\n" +
+					"CREATE PROCEDURE add_user(IN user_name TEXT)\nBEGIN\n    INSERT INTO users (name) VALUES (user_name);\nEND //\n" +
+					"-- no statement\n" +
+					"DELIMITER ;\n" +
+					"CALL add_user('alice');\n"
+			)
+
+			err := sut.ExecBody(context.Background(), execer, body)
+
+			assert.NoError(t, err)
+			assert.Equal(t, []string{
+				"CREATE TABLE users (name TEXT)",
+				"CREATE PROCEDURE add_user(IN user_name TEXT)\nBEGIN\n    INSERT INTO users (name) VALUES (user_name);\nEND",
+				"CALL add_user('alice')",
+			}, execer.statements)
+		})
+
+		t.Run("splits following the lexical rules of MySQL", func(t *testing.T) {
+			for _, test := range []struct {
+				name string
+				body string
+				want []string
+			}{
+				{
+					name: "backslash escaped quote",
+					body: `INSERT INTO notes (body) VALUES ('it\'s; here');` + "\nSELECT 1;",
+					want: []string{`INSERT INTO notes (body) VALUES ('it\'s; here')`, "SELECT 1"},
+				},
+				{
+					name: "backslash escaped double quote",
+					body: `INSERT INTO notes (body) VALUES ("say \"hi;\"");` + "\nSELECT 1;",
+					want: []string{`INSERT INTO notes (body) VALUES ("say \"hi;\"")`, "SELECT 1"},
+				},
+				{
+					name: "escaped backslash before the closing quote",
+					body: `INSERT INTO notes (body) VALUES ('C:\\');` + "\nSELECT 1;",
+					want: []string{`INSERT INTO notes (body) VALUES ('C:\\')`, "SELECT 1"},
+				},
+				{
+					name: "hash line comment",
+					body: "# drop it; later\nSELECT 1; # trailing; comment\nSELECT 2;",
+					want: []string{"# drop it; later\nSELECT 1", "# trailing; comment\nSELECT 2"},
+				},
+				{
+					name: "escaped quote with a custom delimiter",
+					body: "DELIMITER //\nSELECT 'it\\'s // here' //\nDELIMITER ;\n",
+					want: []string{`SELECT 'it\'s // here'`},
+				},
+			} {
+				t.Run(test.name, func(t *testing.T) {
+					var (
+						sut    = migrate.MySQLDialect{}
+						execer = &recordingExecer{}
+					)
+
+					err := sut.ExecBody(context.Background(), execer, test.body)
+
+					assert.NoError(t, err)
+					assert.Equal(t, test.want, execer.statements)
+				})
+			}
+		})
+
+		t.Run("reports the failing statement", func(t *testing.T) {
+			var (
+				sut    = migrate.MySQLDialect{}
+				execer = &recordingExecer{fail: "second"}
+			)
+
+			err := sut.ExecBody(context.Background(), execer, "CREATE TABLE first (id INT);\nCREATE TABLE second (id INT);")
+
+			assert.ErrorContains(t, err, "statement 2 of 2: statement failed")
+		})
+	})
+
+	t.Run("SQLite", func(t *testing.T) {
+		t.Run("uses question mark placeholders", func(t *testing.T) {
+			var sut = migrate.SQLiteDialect{}
+
+			assert.Equal(t, "?", sut.Placeholder(3))
+			assert.Contains(t, sut.UpsertQuery("schema_history"), "ON CONFLICT(migration_name)")
+		})
+
+		t.Run("creates the named table", func(t *testing.T) {
+			var sut = migrate.SQLiteDialect{}
+
+			assert.Contains(t, sut.CreateTableQuery("schema_history"), "CREATE TABLE IF NOT EXISTS schema_history")
+		})
+
+		t.Run("applies a migration file with several statements", func(t *testing.T) {
+			if _, ok := testDialect().(migrate.SQLiteDialect); !ok {
+				t.Skip("test database is not SQLite")
+			}
+
+			assertAppliesMultiStatementMigration(t, migrate.SQLiteDialect{})
+		})
+	})
+}
@@ -0,0 +1,49 @@
+package migrate
+
+import (
+	"strings"
+)
+
+// directivePrefix starts a line in the leading comment block of a migration
+// that changes how the migration is applied, e.g.
+//
+//	-- migrate:data-file users.csv users
+const directivePrefix = "-- migrate:"
+
+type directive struct {
+	name string
+	args []string
+}
+
+// parseDirectives returns the directives found in the comment lines at the
+// top of a migration. Parsing stops at the first line that is neither blank
+// nor a comment.
+func parseDirectives(content []byte) []directive {
+	var directives []directive
+	for line := range strings.Lines(string(content)) {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if !strings.HasPrefix(line, "--") {
+			break
+		}
+
+		value, ok := strings.CutPrefix(line, directivePrefix)
+		if !ok {
+			continue
+		}
+
+		fields := strings.Fields(value)
+		if len(fields) == 0 {
+			continue
+		}
+
+		directives = append(directives, directive{
+			name: fields[0],
+			args: fields[1:],
+		})
+	}
+
+	return directives
+}
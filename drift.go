@@ -0,0 +1,130 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// DriftReport sorts every migration file and every migration known to the
+// database into one category each. Every category lists its migrations in the
+// order they are applied and is never nil, so the report serializes to the
+// same JSON shape regardless of its content.
+type DriftReport struct {
+	// Matching are the applied migrations whose file matches the stored hash.
+	Matching []DriftEntry `json:"matching"`
+	// Altered are the applied migrations whose file has changed since.
+	Altered []DriftEntry `json:"altered"`
+	// Pending are the migration files that are not applied yet, including
+	// dirty ones.
+	Pending []DriftEntry `json:"pending"`
+	// Orphaned are the migrations known to the database whose file is
+	// missing.
+	Orphaned []DriftEntry `json:"orphaned"`
+}
+
+// DriftEntry describes a single migration of a DriftReport.
+type DriftEntry struct {
+	Name string `json:"name"`
+	// StoredHash is the hash recorded in the database, empty if the migration
+	// is unknown to it.
+	StoredHash string `json:"stored_hash,omitempty"`
+	// CurrentHash is the hash of the migration file, empty if it is missing.
+	CurrentHash string    `json:"current_hash,omitempty"`
+	Dirty       bool      `json:"dirty"`
+	AppliedAt   time.Time `json:"applied_at,omitzero"`
+}
+
+// Drifted reports whether the database no longer matches the migration files,
+// because an applied migration was altered or a known one lost its file.
+// Pending migrations are not drift.
+func (r *DriftReport) Drifted() bool {
+	return len(r.Altered) > 0 || len(r.Orphaned) > 0
+}
+
+// DriftReport reports how the migrations recorded in the database compare to
+// the migration files. It finds the same problems as Verify but returns them
+// as data, e.g. to render on a status page. Like Verify, it only reads from
+// the database, not even creating the migrations table.
+func (m *Migrator) DriftReport() (*DriftReport, error) {
+	timeoutCtx, cancel := context.WithTimeout(context.Background(), m.options.migrationTimeout)
+	defer cancel()
+
+	return m.DriftReportContext(timeoutCtx)
+}
+
+// DriftReportContext is like DriftReport but uses ctx for every database call
+// instead of the configured migration timeout.
+func (m *Migrator) DriftReportContext(ctx context.Context) (*DriftReport, error) {
+	conn, err := m.conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer m.closeConn(conn)
+
+	knownMigrations, err := m.readMigrationsKnownToDb(conn, ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	paths, err := m.migrationFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		report = &DriftReport{
+			Matching: []DriftEntry{},
+			Altered:  []DriftEntry{},
+			Pending:  []DriftEntry{},
+			Orphaned: []DriftEntry{},
+		}
+		onDisk = make(map[string]bool, len(paths))
+	)
+	for _, migrationPath := range paths {
+		migrationName := m.recordedName(knownMigrations, migrationPath)
+		onDisk[migrationName] = true
+
+		readBytes, err := m.readMigration(migrationPath)
+		if err != nil {
+			return nil, fmt.Errorf("read migration file %q: %w", m.migrationFileName(migrationPath), err)
+		}
+
+		migration, ok := m.findMigration(knownMigrations, migrationPath)
+		currentHash, unchanged, err := m.hashMatches(readBytes, migration.MigrationHash)
+		if err != nil {
+			return nil, fmt.Errorf("hash migration %q: %w", m.migrationFileName(migrationPath), err)
+		}
+
+		entry := DriftEntry{
+			Name:        migrationName,
+			StoredHash:  migration.MigrationHash,
+			CurrentHash: currentHash,
+			Dirty:       migration.IsDirty,
+			AppliedAt:   migration.AppliedAt,
+		}
+		switch {
+		case !ok || !migration.IsApplied:
+			report.Pending = append(report.Pending, entry)
+		case unchanged:
+			report.Matching = append(report.Matching, entry)
+		default:
+			report.Altered = append(report.Altered, entry)
+		}
+	}
+
+	for _, migration := range knownMigrations.rows {
+		if onDisk[migration.MigrationName] {
+			continue
+		}
+
+		report.Orphaned = append(report.Orphaned, DriftEntry{
+			Name:       migration.MigrationName,
+			StoredHash: migration.MigrationHash,
+			Dirty:      migration.IsDirty,
+			AppliedAt:  migration.AppliedAt,
+		})
+	}
+
+	return report, nil
+}
@@ -0,0 +1,63 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"math"
+)
+
+// DryRun returns the SQL of every pending migration, in the order Migrate
+// would execute it, without executing any of it. It plans the run through the
+// same checks as Migrate, so every run Migrate would refuse, e.g. because of a
+// dirty migration, an altered migration file, a missing migration or an
+// invalid migration ordering, is reported as the same error. The checks run in
+// a transaction that is always rolled back, so apart from ensuring the
+// migrations table exists, it never writes to the database.
+func (m *Migrator) DryRun() ([]string, error) {
+	timeoutCtx, cancel := context.WithTimeout(context.Background(), m.options.migrationTimeout)
+	defer cancel()
+
+	return m.DryRunContext(timeoutCtx)
+}
+
+// DryRunContext is like DryRun but uses ctx for every database call instead
+// of the configured migration timeout.
+func (m *Migrator) DryRunContext(ctx context.Context) ([]string, error) {
+	err := m.VerifyLock()
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := m.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer m.closeConn(conn)
+
+	// Checking the migrations may store upgraded hashes, which the rollback
+	// undoes.
+	tx, err := conn.BeginTx(ctx, m.txOptions())
+	if err != nil {
+		return nil, fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var statements []string
+	_, err = m.migrateWith(tx, ctx, math.MaxInt64, func(pending []pendingMigration) ([]string, error) {
+		statements = make([]string, 0, len(pending))
+		for _, migration := range pending {
+			_, err := m.hashMigration(migration.content)
+			if err != nil {
+				return nil, fmt.Errorf("hash migration %q: %w", m.migrationFileName(migration.path), err)
+			}
+			statements = append(statements, string(migration.content))
+		}
+
+		return nil, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return statements, nil
+}
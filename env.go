@@ -0,0 +1,39 @@
+package migrate
+
+import (
+	"slices"
+	"strings"
+)
+
+// envDirective restricts a migration to the environments it lists, separated
+// by commas, e.g. seed data that only belongs in test databases:
+//
+//	-- migrate:env test,dev
+const envDirective = "env"
+
+// migrationEnvs returns the environments a migration is restricted to, or nil
+// if it runs in every environment.
+func migrationEnvs(directives []directive) []string {
+	var envs []string
+	for _, d := range directives {
+		if d.name != envDirective {
+			continue
+		}
+		for _, arg := range d.args {
+			for env := range strings.SplitSeq(arg, ",") {
+				if env != "" {
+					envs = append(envs, env)
+				}
+			}
+		}
+	}
+	return envs
+}
+
+// runsInEnv reports whether a migration applies in the environment set with
+// WithEnv. Untagged migrations apply everywhere, tagged ones only in the
+// environments they list, so without WithEnv tagged migrations never apply.
+func (m *Migrator) runsInEnv(p pendingMigration) bool {
+	envs := migrationEnvs(p.directives)
+	return envs == nil || slices.Contains(envs, m.options.env)
+}
@@ -0,0 +1,80 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"strings"
+)
+
+// goMigrationPathPrefix marks the paths under which registered Go migrations
+// are listed among the migration files.
+const goMigrationPathPrefix = "go:"
+
+// Register adds a migration implemented in Go, for logic SQL cannot express
+// such as backfilling a column from an external API. name works like a
+// migration file name: it starts with the version that orders the migration
+// among the SQL files, e.g. "003_backfill_emails", and is the name recorded
+// in the migrations table. up runs in a transaction, which in the PerMigration
+// and AllInOne modes is the one the bookkeeping uses as well. As there is no
+// file to hash, the hash is derived from name. ctx carries the timeouts and
+// cancellation of the run, so up can bound its queries and external calls by
+// them. Register must be called before migrating and is not safe for
+// concurrent use.
+func (m *Migrator) Register(name string, up func(ctx context.Context, tx *sql.Tx) error) {
+	if m.goMigrations == nil {
+		m.goMigrations = map[string]func(ctx context.Context, tx *sql.Tx) error{}
+	}
+	m.goMigrations[name] = up
+}
+
+func (m *Migrator) goMigrationPaths() []string {
+	paths := make([]string, 0, len(m.goMigrations))
+	for name := range m.goMigrations {
+		paths = append(paths, goMigrationPathPrefix+name)
+	}
+	return paths
+}
+
+func goMigrationName(migrationPath string) (string, bool) {
+	return strings.CutPrefix(migrationPath, goMigrationPathPrefix)
+}
+
+// readMigration returns the content of a migration. For Go migrations that is
+// a comment naming the migration, which is stable and shows up in DryRun.
+func (m *Migrator) readMigration(migrationPath string) ([]byte, error) {
+	if name, ok := goMigrationName(migrationPath); ok {
+		return []byte("-- Go migration " + name), nil
+	}
+
+	return fs.ReadFile(m.migrations, migrationPath)
+}
+
+// runGoMigration runs a Go migration in db if it is a transaction, or in a new
+// transaction on db otherwise.
+func (m *Migrator) runGoMigration(db execer, ctx context.Context, name string) error {
+	up := m.goMigrations[name]
+
+	if tx, ok := db.(*sql.Tx); ok {
+		return up(ctx, tx)
+	}
+
+	conn, ok := db.(*sql.Conn)
+	if !ok {
+		return fmt.Errorf("cannot begin a transaction on %T", db)
+	}
+
+	tx, err := conn.BeginTx(ctx, m.txOptions())
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	err = up(ctx, tx)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,68 @@
+package migrate
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ExportGraph writes the migrations to w as a graph in DOT format. Each
+// migration is a node labeled with its version and, if it has one, the
+// description of its "-- migrate:description" directive, chained to the next
+// in the order they are applied. It only reads the migration files and never
+// touches the database.
+func (m *Migrator) ExportGraph(w io.Writer) error {
+	paths, err := m.migrationFiles()
+	if err != nil {
+		return err
+	}
+
+	var (
+		names  = make([]string, 0, len(paths))
+		labels = make([]string, 0, len(paths))
+	)
+	for _, migrationPath := range paths {
+		fileName := m.migrationFileName(migrationPath)
+
+		version, err := parseVersion(fileName)
+		if err != nil {
+			return err
+		}
+		readBytes, err := m.readMigration(migrationPath)
+		if err != nil {
+			return fmt.Errorf("read migration file %q: %w", fileName, err)
+		}
+
+		label := strconv.FormatInt(version, 10)
+		if description := migrationDescription(parseDirectives(readBytes)); description != "" {
+			label += ": " + description
+		}
+		names = append(names, m.migrationName(migrationPath))
+		labels = append(labels, label)
+	}
+
+	var graph strings.Builder
+	graph.WriteString("digraph migrations {\n")
+	graph.WriteString("\trankdir=LR;\n")
+	for i, name := range names {
+		fmt.Fprintf(&graph, "\t%s [label=%s];\n", dotQuote(name), dotQuote(labels[i]))
+	}
+	for i := 1; i < len(names); i++ {
+		fmt.Fprintf(&graph, "\t%s -> %s;\n", dotQuote(names[i-1]), dotQuote(names[i]))
+	}
+	graph.WriteString("}\n")
+
+	_, err = io.WriteString(w, graph.String())
+	if err != nil {
+		return fmt.Errorf("write graph: %w", err)
+	}
+
+	return nil
+}
+
+func dotQuote(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, `"`, `\"`)
+	return `"` + value + `"`
+}
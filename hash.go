@@ -0,0 +1,79 @@
+package migrate
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+)
+
+// SHA256Hasher is the default hasher. It returns the hex encoded SHA-256
+// digest of content.
+func SHA256Hasher(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// legacyHash is the hash stored by versions that hashed the %v formatting
+// of a migration instead of its bytes. Such hashes are still accepted, so
+// upgrading does not report every applied migration as changed.
+func legacyHash(content []byte) string {
+	return SHA256Hasher(fmt.Appendf(nil, "%v", content))
+}
+
+// utf8BOM is the byte order mark some editors put in front of UTF-8 files.
+var utf8BOM = []byte("\xef\xbb\xbf")
+
+// normalizeContent strips the parts of a migration editors tend to change
+// without changing the SQL: a leading byte order mark, whitespace at the end
+// of every line and trailing blank lines. It returns a new slice, as content
+// itself is executed as it is.
+func normalizeContent(content []byte) []byte {
+	normalized := make([]byte, 0, len(content))
+	for i, line := range bytes.Split(bytes.TrimPrefix(content, utf8BOM), []byte("\n")) {
+		if i > 0 {
+			normalized = append(normalized, '\n')
+		}
+		// A carriage return is a line ending, so the whitespace before it is
+		// trailing as well.
+		body, hasCR := bytes.CutSuffix(line, []byte("\r"))
+		normalized = append(normalized, bytes.TrimRight(body, " \t")...)
+		if hasCR {
+			normalized = append(normalized, '\r')
+		}
+	}
+
+	return bytes.TrimRight(normalized, " \t\r\n")
+}
+
+// HashOf returns the hash Migrate stores for the migration recorded as name
+// and compares against to detect altered files, computed by the configured
+// hasher from the migration file and its data files, e.g. for a pre-commit
+// hook that checks applied migrations were not edited. It does not use the
+// database.
+func (m *Migrator) HashOf(name string) (string, error) {
+	paths, err := m.migrationFiles()
+	if err != nil {
+		return "", err
+	}
+
+	for _, migrationPath := range paths {
+		if m.migrationName(migrationPath) != name {
+			continue
+		}
+
+		readBytes, err := m.readMigration(migrationPath)
+		if err != nil {
+			return "", fmt.Errorf("read migration file %q: %w", m.migrationFileName(migrationPath), err)
+		}
+
+		migrationHash, err := m.hashMigration(readBytes)
+		if err != nil {
+			return "", fmt.Errorf("hash migration %q: %w", m.migrationFileName(migrationPath), err)
+		}
+
+		return migrationHash, nil
+	}
+
+	return "", fmt.Errorf("migration %q: %w", name, ErrMigrationTargetNotFound)
+}
@@ -0,0 +1,61 @@
+package migrate
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+)
+
+// fileHash is the outcome of reading and hashing the file of an applied
+// migration.
+type fileHash struct {
+	hash      string
+	unchanged bool
+	err       error
+}
+
+// hashAppliedMigrations reads and hashes the files of every applied migration
+// in paths, comparing them to their stored hashes. The files are independent
+// of each other, so they are hashed on up to GOMAXPROCS workers. The results
+// are indexed like paths and are empty for migrations that are not applied.
+func (m *Migrator) hashAppliedMigrations(paths []string, knownMigrations migrationRows) []fileHash {
+	var (
+		wg      sync.WaitGroup
+		results = make([]fileHash, len(paths))
+		workers = make(chan struct{}, runtime.GOMAXPROCS(0))
+	)
+	for i, migrationPath := range paths {
+		migration, ok := m.findMigration(knownMigrations, migrationPath)
+		if !ok || !migration.IsApplied {
+			continue
+		}
+
+		workers <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-workers }()
+
+			results[i] = m.hashAppliedMigration(migrationPath, migration.MigrationHash)
+		}()
+	}
+	wg.Wait()
+
+	return results
+}
+
+func (m *Migrator) hashAppliedMigration(migrationPath string, storedHash string) fileHash {
+	fileName := m.migrationFileName(migrationPath)
+
+	readBytes, err := m.readMigration(migrationPath)
+	if err != nil {
+		return fileHash{err: fmt.Errorf("read migration file %q: %w", fileName, err)}
+	}
+
+	currentHash, unchanged, err := m.hashMatches(readBytes, storedHash)
+	if err != nil {
+		return fileHash{err: fmt.Errorf("hash migration %q: %w", fileName, err)}
+	}
+
+	return fileHash{hash: currentHash, unchanged: unchanged}
+}
@@ -0,0 +1,19 @@
+package migrate
+
+import (
+	"fmt"
+)
+
+// callHook calls a user supplied hook and turns a panic into an error, so a
+// misbehaving hook fails the migration instead of crashing in the middle of
+// it with a transaction or the dirty flag left behind.
+func callHook(name string, hook func()) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%s hook panicked: %v: %w", name, r, ErrHookPanicked)
+		}
+	}()
+
+	hook()
+	return nil
+}
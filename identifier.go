@@ -0,0 +1,58 @@
+package migrate
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// maxIdentifierLength is the longest identifier PostgreSQL accepts without
+// silently truncating it.
+const maxIdentifierLength = 63
+
+var identifierPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// isIdentifier reports whether name is a plain SQL identifier that is safe to
+// interpolate into a query. Identifiers cannot be passed as query parameters,
+// so anything interpolated must pass this allowlist first.
+func isIdentifier(name string) bool {
+	return len(name) <= maxIdentifierLength && identifierPattern.MatchString(name)
+}
+
+// maxMigrationNameLength is the length of the migration_name column.
+const maxMigrationNameLength = 255
+
+// validateMigrationName rejects names that do not fit the migration_name
+// column or contain characters no file name should, such as control
+// characters. Names are always passed as query parameters, so this guards
+// against surprising rows rather than injection.
+func validateMigrationName(name string) error {
+	if !utf8.ValidString(name) {
+		return fmt.Errorf("%q is not valid UTF-8: %w", name, ErrInvalidMigrationName)
+	}
+	if length := utf8.RuneCountInString(name); length > maxMigrationNameLength {
+		return fmt.Errorf("%q has %d characters, at most %d are allowed: %w", name, length, maxMigrationNameLength, ErrInvalidMigrationName)
+	}
+	if strings.ContainsFunc(name, unicode.IsControl) {
+		return fmt.Errorf("%q contains a control character: %w", name, ErrInvalidMigrationName)
+	}
+
+	return nil
+}
+
+// isQualifiedIdentifier reports whether name is a plain or schema-qualified
+// SQL identifier that is safe to interpolate into a query.
+func isQualifiedIdentifier(name string) bool {
+	parts := strings.Split(name, ".")
+	if len(parts) > 2 {
+		return false
+	}
+	for _, part := range parts {
+		if !isIdentifier(part) {
+			return false
+		}
+	}
+	return true
+}
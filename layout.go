@@ -0,0 +1,165 @@
+package migrate
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+)
+
+// Layout controls how migrations are laid out in the migrations filesystem.
+type Layout int
+
+const (
+	// FlatLayout treats every ".sql" file, or every file with the extension set
+	// with WithExtension, as a migration named after the file, with an
+	// optional ".down.sql" file next to it, e.g.
+	//
+	//	001_init.sql
+	//	001_init.down.sql
+	FlatLayout Layout = iota
+	// DirectoryLayout treats every directory holding an "up.sql" file as a
+	// migration named after the directory, with an optional "down.sql" file
+	// next to it, e.g.
+	//
+	//	001_init/up.sql
+	//	001_init/down.sql
+	DirectoryLayout
+)
+
+const (
+	upMigrationFile   = "up.sql"
+	downMigrationFile = "down.sql"
+)
+
+// isUpMigration reports whether the walked file is a migration applied by
+// Migrate.
+func (m *Migrator) isUpMigration(migrationPath string, d fs.DirEntry) bool {
+	if d.IsDir() {
+		return false
+	}
+
+	if m.options.layout == DirectoryLayout {
+		return d.Name() == upMigrationFile && path.Dir(migrationPath) != "."
+	}
+
+	return strings.HasSuffix(d.Name(), m.options.extension) && !isDownMigration(d.Name())
+}
+
+// matchesFilter reports whether a migration file matches the glob set with
+// WithFilter. Without a filter every file matches.
+func (m *Migrator) matchesFilter(migrationPath string) (bool, error) {
+	if m.options.filter == "" {
+		return true, nil
+	}
+
+	matched, err := path.Match(m.options.filter, migrationPath)
+	if err != nil {
+		return false, fmt.Errorf("filter %q: %w", m.options.filter, err)
+	}
+
+	return matched, nil
+}
+
+// migrationFileName returns the name a migration is known by before the name
+// transformer is applied: the file name in the flat layout, the directory
+// name in the directory layout and the registered name of a Go migration. Its
+// version is parsed from this name.
+func (m *Migrator) migrationFileName(migrationPath string) string {
+	if name, ok := goMigrationName(migrationPath); ok {
+		return name
+	}
+	if m.options.layout == DirectoryLayout {
+		return path.Base(path.Dir(migrationPath))
+	}
+
+	return path.Base(migrationPath)
+}
+
+// migrationName returns the name recorded in the migrations table: the file
+// name, or with WithKeyByPath the path within the migrations filesystem, put
+// through the name transformer.
+func (m *Migrator) migrationName(migrationPath string) string {
+	if m.options.keyByPath {
+		return m.options.nameTransformer(m.migrationKeyPath(migrationPath))
+	}
+
+	return m.options.nameTransformer(m.migrationFileName(migrationPath))
+}
+
+func (m *Migrator) migrationKeyPath(migrationPath string) string {
+	if name, ok := goMigrationName(migrationPath); ok {
+		return name
+	}
+	if m.options.layout == DirectoryLayout {
+		return path.Dir(migrationPath)
+	}
+
+	return migrationPath
+}
+
+// findMigration returns the row recorded for a migration. With WithKeyByPath,
+// rows recorded by file name before the option was enabled are matched as
+// well, so upgrading does not apply those migrations again. With
+// WithIdentityByHash, an applied row with the same hash is matched when there
+// is none by name. Such rows keep their original name, so callers writing a
+// found row must use its MigrationName.
+func (m *Migrator) findMigration(knownMigrations migrationRows, migrationPath string) (migrationRow, bool) {
+	migration, ok := knownMigrations.find(m.migrationName(migrationPath))
+	if !ok && m.options.keyByPath {
+		migration, ok = knownMigrations.find(m.options.nameTransformer(m.migrationFileName(migrationPath)))
+	}
+	if !ok && m.options.identityByHash {
+		migration, ok = m.findMigrationByHash(knownMigrations, migrationPath)
+	}
+
+	return migration, ok
+}
+
+// findMigrationByHash returns the applied row whose hash matches the current
+// hash of a migration, so a renamed file is recognized as applied. A file
+// that cannot be read or hashed matches nothing; the error is reported where
+// the file is read for executing or checking it.
+func (m *Migrator) findMigrationByHash(knownMigrations migrationRows, migrationPath string) (migrationRow, bool) {
+	readBytes, err := m.readMigration(migrationPath)
+	if err != nil {
+		return migrationRow{}, false
+	}
+	migrationHash, err := m.hashMigration(readBytes)
+	if err != nil {
+		return migrationRow{}, false
+	}
+
+	for _, migration := range knownMigrations.rows {
+		if migration.IsApplied && migration.MigrationHash == migrationHash {
+			return migration, true
+		}
+	}
+	return migrationRow{}, false
+}
+
+// recordedName returns the name a migration is recorded under, which is the
+// name of its existing row if there is one.
+func (m *Migrator) recordedName(knownMigrations migrationRows, migrationPath string) string {
+	if migration, ok := m.findMigration(knownMigrations, migrationPath); ok {
+		return migration.MigrationName
+	}
+
+	return m.migrationName(migrationPath)
+}
+
+func (m *Migrator) downMigrationPath(upPath string) string {
+	if m.options.layout == DirectoryLayout {
+		return path.Join(path.Dir(upPath), downMigrationFile)
+	}
+
+	return path.Join(path.Dir(upPath), strings.TrimSuffix(path.Base(upPath), m.options.extension)+downMigrationSuffix)
+}
+
+func (m *Migrator) downMigrationFileName(upPath string) string {
+	if m.options.layout == DirectoryLayout {
+		return path.Join(m.migrationFileName(upPath), downMigrationFile)
+	}
+
+	return path.Base(m.downMigrationPath(upPath))
+}
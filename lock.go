@@ -0,0 +1,246 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"hash/fnv"
+)
+
+// LockMode controls what Migrate and Rollback do when another process holds
+// the migration lock.
+type LockMode int
+
+const (
+	// LockWait blocks until the lock is free, bounded by the context.
+	LockWait LockMode = iota
+	// LockFailFast returns ErrMigrationLocked right away.
+	LockFailFast
+)
+
+// Locker is implemented by dialects that can hold a database wide lock for
+// the lifetime of a connection, so only one Migrator at a time changes the
+// migrations table. Migrations on a dialect that is not a Locker run without
+// a lock.
+type Locker interface {
+	// Lock acquires the lock identified by table on conn. If wait is false
+	// and the lock is held elsewhere, it returns false instead of blocking.
+	Lock(ctx context.Context, conn *sql.Conn, table string, wait bool) (bool, error)
+	// Unlock releases a lock acquired by Lock on the same connection.
+	Unlock(ctx context.Context, conn *sql.Conn, table string) error
+}
+
+// LockBreaker is implemented by dialects that can release a migration lock
+// held by another session, which ForceUnlock needs.
+type LockBreaker interface {
+	// BreakLock ends every other session holding the lock identified by
+	// table and reports whether there was one.
+	BreakLock(ctx context.Context, conn *sql.Conn, table string) (bool, error)
+}
+
+// Lock takes a session level advisory lock keyed by a hash of table.
+func (PostgresDialect) Lock(ctx context.Context, conn *sql.Conn, table string, wait bool) (bool, error) {
+	if wait {
+		_, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", lockKey(table))
+		return err == nil, err
+	}
+
+	var acquired bool
+	err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", lockKey(table)).Scan(&acquired)
+	return acquired, err
+}
+
+func (PostgresDialect) Unlock(ctx context.Context, conn *sql.Conn, table string) error {
+	_, err := conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", lockKey(table))
+	return err
+}
+
+// BreakLock terminates the backends holding the advisory lock. pg_locks
+// shows a bigint advisory key split into its high and low 32 bits.
+func (PostgresDialect) BreakLock(ctx context.Context, conn *sql.Conn, table string) (bool, error) {
+	key := uint64(lockKey(table))
+	rows, err := conn.QueryContext(ctx, `SELECT pg_terminate_backend(pid) FROM pg_locks
+		WHERE locktype = 'advisory' AND granted AND pid <> pg_backend_pid()
+		AND database = (SELECT oid FROM pg_database WHERE datname = current_database())
+		AND classid = $1 AND objid = $2 AND objsubid = 1`, int64(key>>32), int64(key&0xffffffff))
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	var broken bool
+	for rows.Next() {
+		var terminated bool
+		err = rows.Scan(&terminated)
+		if err != nil {
+			return false, err
+		}
+		broken = broken || terminated
+	}
+
+	return broken, rows.Err()
+}
+
+// Lock takes a named lock with GET_LOCK. Lock names are limited to 64
+// characters, so the name is derived from a hash of table.
+func (MySQLDialect) Lock(ctx context.Context, conn *sql.Conn, table string, wait bool) (bool, error) {
+	timeout := 0
+	if wait {
+		timeout = -1
+	}
+
+	var acquired sql.NullInt64
+	err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", mysqlLockName(table), timeout).Scan(&acquired)
+	return acquired.Valid && acquired.Int64 == 1, err
+}
+
+func (MySQLDialect) Unlock(ctx context.Context, conn *sql.Conn, table string) error {
+	_, err := conn.ExecContext(ctx, "SELECT RELEASE_LOCK(?)", mysqlLockName(table))
+	return err
+}
+
+// BreakLock kills the connection holding the named lock.
+func (MySQLDialect) BreakLock(ctx context.Context, conn *sql.Conn, table string) (bool, error) {
+	var holder sql.NullInt64
+	err := conn.QueryRowContext(ctx, "SELECT IS_USED_LOCK(?)", mysqlLockName(table)).Scan(&holder)
+	if err != nil || !holder.Valid {
+		return false, err
+	}
+
+	_, err = conn.ExecContext(ctx, fmt.Sprintf("KILL %d", holder.Int64))
+	return err == nil, err
+}
+
+func lockKey(table string) int64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(table))
+	return int64(h.Sum64())
+}
+
+func mysqlLockName(table string) string {
+	return fmt.Sprintf("go-migrate:%x", uint64(lockKey(table)))
+}
+
+// ForceUnlock releases the migration lock if another session holds it, by
+// ending that session, e.g. when a migrating process hangs. The lock is tied
+// to a session, so a process that crashed releases it along with its
+// connection. Only use it once you are sure the holder is not migrating
+// anymore, as its migration is interrupted.
+func (m *Migrator) ForceUnlock() error {
+	timeoutCtx, cancel := context.WithTimeout(context.Background(), m.options.migrationTimeout)
+	defer cancel()
+
+	return m.ForceUnlockContext(timeoutCtx)
+}
+
+// ForceUnlockContext is like ForceUnlock but uses ctx for every database call
+// instead of the configured migration timeout.
+func (m *Migrator) ForceUnlockContext(ctx context.Context) error {
+	breaker, ok := m.dialect().(LockBreaker)
+	if !ok {
+		return fmt.Errorf("force unlock: dialect %T cannot break locks", m.dialect())
+	}
+
+	conn, err := m.conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer m.closeConn(conn)
+
+	broken, err := breaker.BreakLock(ctx, conn, m.lockName())
+	if err != nil {
+		return fmt.Errorf("force unlock: %w", err)
+	}
+	if broken {
+		m.options.logger.WarnContext(ctx, "ended session holding the migration lock", "lock", m.lockName())
+	}
+
+	return nil
+}
+
+// connectLocked is like connect but holds the migration lock before the
+// migrations table is created. The returned release function unlocks and
+// closes the connection and must always be called.
+func (m *Migrator) connectLocked(ctx context.Context) (*sql.Conn, func(), error) {
+	err := m.options.validate()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var (
+		conn    *sql.Conn
+		release func()
+	)
+	err = m.retryConnect(ctx, func() error {
+		attemptConn, err := m.openConn(ctx)
+		if err != nil {
+			return err
+		}
+
+		unlock, err := m.lock(attemptConn, ctx)
+		if err != nil {
+			m.closeConn(attemptConn)
+			return err
+		}
+		attemptRelease := func() {
+			unlock()
+			m.closeConn(attemptConn)
+		}
+
+		err = m.createTable(attemptConn, ctx)
+		if err != nil {
+			attemptRelease()
+			return err
+		}
+
+		conn, release = attemptConn, attemptRelease
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return conn, release, nil
+}
+
+// lock acquires the migration lock on conn. The returned unlock function
+// releases it but leaves conn open.
+func (m *Migrator) lock(conn *sql.Conn, ctx context.Context) (func(), error) {
+	locker, ok := m.dialect().(Locker)
+	if !ok {
+		return func() {}, nil
+	}
+
+	var (
+		name    = m.lockName()
+		wait    = m.options.lockMode == LockWait
+		lockCtx = ctx
+	)
+	if wait && m.options.lockTimeout > 0 {
+		var cancel context.CancelFunc
+		lockCtx, cancel = context.WithTimeout(ctx, m.options.lockTimeout)
+		defer cancel()
+	}
+
+	acquired, err := locker.Lock(lockCtx, conn, name, wait)
+	if err != nil && ctx.Err() == nil && errors.Is(lockCtx.Err(), context.DeadlineExceeded) {
+		return nil, fmt.Errorf("waited %s for the migration lock: %w", m.options.lockTimeout, ErrMigrationLocked)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("acquire migration lock: %w", err)
+	}
+	if !acquired {
+		return nil, ErrMigrationLocked
+	}
+
+	return func() {
+		// The lock belongs to the session, so a connection we failed to unlock
+		// must not go back to the pool.
+		err := locker.Unlock(context.WithoutCancel(ctx), conn, name)
+		if err != nil {
+			_ = conn.Raw(func(any) error { return driver.ErrBadConn })
+		}
+	}, nil
+}
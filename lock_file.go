@@ -0,0 +1,148 @@
+package migrate
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"strings"
+)
+
+// LockFileName is the name of the manifest GenerateLock writes and
+// WithLockFile reads.
+const LockFileName = "migrations.lock"
+
+// GenerateLock writes a manifest of every migration to w, one line with its
+// hash and name each, as computed by HashOf. Commit it as migrations.lock and
+// pass the directory holding it to WithLockFile, so editing a migration
+// without regenerating the manifest fails the build, regardless of what any
+// database has applied. It does not use the database.
+func (m *Migrator) GenerateLock(w io.Writer) error {
+	hashes, err := m.migrationHashes()
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range hashes {
+		_, err = fmt.Fprintf(w, "%s  %s\n", entry.hash, entry.name)
+		if err != nil {
+			return fmt.Errorf("write %s: %w", LockFileName, err)
+		}
+	}
+
+	return nil
+}
+
+// VerifyLock checks the migrations against the manifest set with
+// WithLockFile, as Migrate does before touching the database, e.g. as a CI
+// step that needs no database. It returns an error joining one error per
+// mismatch, each wrapping ErrLockFileMismatch, and nil if no manifest is set.
+func (m *Migrator) VerifyLock() error {
+	if m.options.lockFile == nil {
+		return nil
+	}
+
+	locked, err := readLockFile(m.options.lockFile)
+	if err != nil {
+		return err
+	}
+
+	hashes, err := m.migrationHashes()
+	if err != nil {
+		return err
+	}
+
+	var (
+		errs   []error
+		listed = make(map[string]bool, len(hashes))
+	)
+	for _, entry := range hashes {
+		listed[entry.name] = true
+
+		lockedHash, ok := locked[entry.name]
+		switch {
+		case !ok:
+			errs = append(errs, fmt.Errorf("migration %q is not listed in %s: %w", entry.name, LockFileName, ErrLockFileMismatch))
+		case lockedHash != entry.hash:
+			errs = append(errs, fmt.Errorf("migration %q has hash %s, but %s lists %s: %w", entry.name, entry.hash, LockFileName, lockedHash, ErrLockFileMismatch))
+		}
+	}
+	for name := range locked {
+		if !listed[name] {
+			errs = append(errs, fmt.Errorf("migration %q is listed in %s but missing: %w", name, LockFileName, ErrLockFileMismatch))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+type migrationHash struct {
+	name string
+	hash string
+}
+
+// migrationHashes returns the recorded name and hash of every migration, in
+// order.
+func (m *Migrator) migrationHashes() ([]migrationHash, error) {
+	paths, err := m.migrationFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	hashes := make([]migrationHash, 0, len(paths))
+	for _, migrationPath := range paths {
+		readBytes, err := m.readMigration(migrationPath)
+		if err != nil {
+			return nil, fmt.Errorf("read migration file %q: %w", m.migrationFileName(migrationPath), err)
+		}
+
+		hash, err := m.hashMigration(readBytes)
+		if err != nil {
+			return nil, fmt.Errorf("hash migration %q: %w", m.migrationFileName(migrationPath), err)
+		}
+
+		hashes = append(hashes, migrationHash{name: m.migrationName(migrationPath), hash: hash})
+	}
+
+	return hashes, nil
+}
+
+// readLockFile returns the hashes listed in the manifest in fsys by migration
+// name. Empty lines and lines starting with # are ignored.
+func readLockFile(fsys fs.FS) (map[string]string, error) {
+	file, err := fsys.Open(LockFileName)
+	if err != nil {
+		return nil, fmt.Errorf("open %s: %w", LockFileName, err)
+	}
+	defer file.Close()
+
+	var (
+		locked  = make(map[string]string)
+		scanner = bufio.NewScanner(file)
+		line    int
+	)
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		hash, name, ok := strings.Cut(text, " ")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("%s line %d: expected a hash and a migration name", LockFileName, line)
+		}
+		if _, ok := locked[name]; ok {
+			return nil, fmt.Errorf("%s line %d: migration %q is listed twice", LockFileName, line, name)
+		}
+		locked[name] = hash
+	}
+	err = scanner.Err()
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", LockFileName, err)
+	}
+
+	return locked, nil
+}
@@ -0,0 +1,41 @@
+package migrate
+
+import (
+	"fmt"
+	"time"
+)
+
+// Metrics receives the outcome of every migration Migrate applies, so it can
+// be wired to a metrics system such as Prometheus without this package
+// depending on one. With a parallelism above one, its methods must be safe
+// for concurrent use.
+type Metrics interface {
+	// OnMigrationApplied is called after a migration was applied, with how
+	// long it took.
+	OnMigrationApplied(name string, duration time.Duration)
+	// OnMigrationFailed is called after a migration failed, with the error it
+	// failed with.
+	OnMigrationFailed(name string, err error)
+}
+
+// reportMetrics reports the outcome of a migration to the configured Metrics.
+// Like the after each hook, a panicking implementation fails a migration that
+// succeeded.
+func (m *Migrator) reportMetrics(name string, err error, duration time.Duration) error {
+	if m.options.metrics == nil {
+		return err
+	}
+
+	hookErr := callHook("metrics", func() {
+		if err != nil {
+			m.options.metrics.OnMigrationFailed(name, err)
+		} else {
+			m.options.metrics.OnMigrationApplied(name, duration)
+		}
+	})
+	if hookErr != nil && err == nil {
+		return fmt.Errorf("migration %q: %w", name, hookErr)
+	}
+
+	return err
+}
@@ -1,46 +1,82 @@
 package migrate
 
 import (
+	"cmp"
 	"context"
-	"crypto/sha256"
 	"database/sql"
-	"embed"
+	"errors"
 	"fmt"
 	"io/fs"
+	"log/slog"
+	"math"
+	"slices"
+	"strings"
 	"time"
-
-	_ "github.com/lib/pq" // PostgreSQL driver
 )
 
-//go:embed migration_table_query.sql
-var migrationTableQuery string
+// downMigrationSuffix marks a file as the rollback script of the migration
+// with the same name, e.g. "001_init.down.sql" reverses "001_init.sql".
+const downMigrationSuffix = ".down.sql"
 
 var (
-	ErrMigrationFileChanged = fmt.Errorf("migration file has changed")
-	ErrMigrationFailed      = fmt.Errorf("migration failed")
-	ErrDirtyMigration       = fmt.Errorf("dirty migration state")
+	ErrMigrationFileChanged      = fmt.Errorf("migration file has changed")
+	ErrMigrationFileMissing      = fmt.Errorf("migration file is missing")
+	ErrMigrationFailed           = fmt.Errorf("migration failed")
+	ErrDirtyMigration            = fmt.Errorf("dirty migration state")
+	ErrNoDownMigration           = fmt.Errorf("no down migration")
+	ErrDuplicateMigrationVersion = fmt.Errorf("duplicate migration version")
+	ErrDuplicateMigrationName    = fmt.Errorf("duplicate migration name")
+	ErrMigrationLocked           = fmt.Errorf("migrations are locked by another process")
+	ErrHookPanicked              = fmt.Errorf("hook panicked")
+	ErrMigrationTargetNotFound   = fmt.Errorf("migration target not found")
+	ErrInvalidMigrationsTable    = fmt.Errorf("invalid migrations table")
+	ErrMigrationsAlreadyApplied  = fmt.Errorf("migrations are already applied")
+	ErrOutOfOrderMigration       = fmt.Errorf("migration is out of order")
+	ErrNoMigrations              = fmt.Errorf("no migrations found")
+	ErrTooManyMigrations         = fmt.Errorf("too many migrations")
+	ErrMigrationNotConfirmed     = fmt.Errorf("migration was not confirmed")
+	ErrInvalidMigrationName      = fmt.Errorf("invalid migration name")
+	ErrLockFileMismatch          = fmt.Errorf("migrations do not match the lock file")
+	ErrResetNotAllowed           = fmt.Errorf("reset is not allowed")
 )
 
 type migrationRow struct {
-	MigrationName string `json:"migration_name,omitempty"`
-	MigrationHash string `json:"migration_hash,omitempty"`
-	IsApplied     bool   `json:"is_applied,omitempty"`
-	IsDirty       bool   `json:"is_dirty,omitempty"`
+	MigrationName string    `json:"migration_name,omitempty"`
+	MigrationHash string    `json:"migration_hash,omitempty"`
+	IsApplied     bool      `json:"is_applied,omitempty"`
+	IsDirty       bool      `json:"is_dirty,omitempty"`
+	AppliedAt     time.Time `json:"applied_at,omitempty"`
+	Description   string    `json:"description,omitempty"`
+	AppliedBy     string    `json:"applied_by,omitempty"`
+	// Duration is how long the migration took to execute when it was last
+	// applied, stored with millisecond precision.
+	Duration time.Duration `json:"duration,omitempty"`
 }
 
 type Migrator struct {
-	options    *options
-	db         *sql.DB
-	migrations embed.FS
+	options      *options
+	db           *sql.DB
+	migrations   fs.FS
+	goMigrations map[string]func(ctx context.Context, tx *sql.Tx) error
 }
 
-func NewMigrator(db *sql.DB, migrations embed.FS, opts ...func(*options)) *Migrator {
+func NewMigrator(db *sql.DB, migrations fs.FS, opts ...func(*options)) *Migrator {
 	opt := &options{
 		migrationTimeout: 10 * time.Second,
+		nameTransformer:  func(filename string) string { return filename },
+		parallelism:      1,
+		tableName:        "migrations",
+		hasher:           SHA256Hasher,
+		extension:        ".sql",
+		clock:            time.Now,
+		logger:           slog.New(slog.DiscardHandler),
 	}
 	for _, o := range opts {
 		o(opt)
 	}
+	if len(opt.additionalFS) > 0 {
+		migrations = mergedFS{sources: append([]fs.FS{migrations}, opt.additionalFS...), policy: opt.versionConflict}
+	}
 
 	return &Migrator{
 		options:    opt,
@@ -49,143 +85,685 @@ func NewMigrator(db *sql.DB, migrations embed.FS, opts ...func(*options)) *Migra
 	}
 }
 
+// NewValidatedMigrator is like NewMigrator but validates the options up front,
+// so a misconfigured Migrator fails at construction instead of on its first
+// run with a confusing error, such as a context that is cancelled right away
+// because of a zero migration timeout.
+func NewValidatedMigrator(db *sql.DB, migrations fs.FS, opts ...func(*options)) (*Migrator, error) {
+	m := NewMigrator(db, migrations, opts...)
+
+	err := m.options.validateStrict()
+	if err != nil {
+		return nil, fmt.Errorf("invalid migrator options: %w", err)
+	}
+
+	return m, nil
+}
+
+// Migrate applies all pending migrations, bounded by the configured migration
+// timeout.
 func (m *Migrator) Migrate() error {
 	timeoutCtx, cancel := context.WithTimeout(context.Background(), m.options.migrationTimeout)
 	defer cancel()
 
-	conn, err := m.db.Conn(timeoutCtx)
+	return m.MigrateContext(timeoutCtx)
+}
+
+// MigrateContext applies all pending migrations using ctx for every database
+// call, so cancelling ctx aborts an in-flight migration. The configured
+// migration timeout is not applied; ctx alone controls the deadline.
+func (m *Migrator) MigrateContext(ctx context.Context) error {
+	_, err := m.migrate(ctx, math.MaxInt64)
+	return err
+}
+
+// migrate applies the pending migrations with a version up to maxVersion.
+func (m *Migrator) migrate(ctx context.Context, maxVersion int64) (Result, error) {
+	err := m.VerifyLock()
 	if err != nil {
-		return fmt.Errorf("get connection: %w", err)
+		return Result{}, err
 	}
-	defer conn.Close()
 
-	_, err = conn.ExecContext(timeoutCtx, migrationTableQuery)
+	conn, release, err := m.connectLocked(ctx)
 	if err != nil {
-		return fmt.Errorf("create migrations table: %w", err)
+		return Result{}, err
 	}
+	defer release()
 
-	knownMigrations, err := getMigrationsKnownToDb(conn, timeoutCtx)
+	return m.migrateConn(conn, ctx, maxVersion)
+}
+
+// migrateConn applies the pending migrations with a version up to maxVersion
+// on conn, which must hold the migration lock, and runs the after commit hook.
+func (m *Migrator) migrateConn(conn *sql.Conn, ctx context.Context, maxVersion int64) (Result, error) {
+	result, err := m.migrateWith(conn, ctx, maxVersion, func(pending []pendingMigration) ([]string, error) {
+		return m.applyMigrations(conn, ctx, pending)
+	})
 	if err != nil {
-		return err
+		return result, err
+	}
+	m.options.logger.InfoContext(ctx, "committed migrations", "count", result.Count)
+
+	if m.options.afterCommit != nil {
+		err = m.options.afterCommit(ctx, result.Applied)
+		if err != nil {
+			return result, fmt.Errorf("after commit hook: %w", err)
+		}
+	}
+
+	return result, nil
+}
+
+// migrateWith checks the migrations known to db and passes the pending ones
+// with a version up to maxVersion to apply.
+func (m *Migrator) migrateWith(db querier, ctx context.Context, maxVersion int64, apply func([]pendingMigration) ([]string, error)) (Result, error) {
+	knownMigrations, err := m.getMigrationsKnownToDb(db, ctx)
+	if err != nil {
+		return Result{}, err
 	}
 	if hasDirtyMigration(knownMigrations) {
-		return ErrDirtyMigration
+		return Result{}, ErrDirtyMigration
 	}
 
-	// We check if any of the migration files have been altered.
-	// It is currently undefined what to do if so
-	err = fs.WalkDir(m.migrations, ".", checkIfMigrationsAreAltered(m.migrations, knownMigrations))
+	// The migration files are walked once and the paths shared by every
+	// step, like the known migrations.
+	paths, err := m.migrationFiles()
 	if err != nil {
-		return ErrMigrationFileChanged
+		return Result{}, err
 	}
 
-	// We "walk" the migrations directory and execute each migration file
-	// if they are not already applied.
-	err = fs.WalkDir(m.migrations, ".", handleMigration(conn, timeoutCtx, m.migrations, knownMigrations))
+	err = m.checkEmpty(ctx, paths)
 	if err != nil {
-		return fmt.Errorf("walk migrations: %w", err)
+		return Result{}, err
+	}
+
+	err = m.checkMaxMigrations(paths)
+	if err != nil {
+		return Result{}, err
+	}
+
+	err = m.checkMissing(ctx, paths, knownMigrations)
+	if err != nil {
+		return Result{}, err
+	}
+
+	err = m.checkMigrations(db, ctx, paths, knownMigrations)
+	if err != nil {
+		return Result{}, err
+	}
+
+	// We collect the migrations that are not applied yet and execute them
+	// in order.
+	pending, err := m.pendingMigrations(ctx, paths, knownMigrations)
+	if err != nil {
+		return Result{}, err
+	}
+	pending, err = m.pendingUpTo(pending, maxVersion)
+	if err != nil {
+		return Result{}, err
+	}
+	err = m.checkOrder(ctx, paths, knownMigrations, pending)
+	if err != nil {
+		return Result{}, err
+	}
+	err = m.confirmDestructive(pending)
+	if err != nil {
+		return Result{}, err
+	}
+
+	appliedMigrations, err := apply(pending)
+	result, resultErr := m.result(paths, knownMigrations, appliedMigrations)
+	if err != nil {
+		return result, fmt.Errorf("apply migrations: %w", err)
+	}
+
+	return result, resultErr
+}
+
+// checkMigrations checks if any of the applied migration files have been
+// altered and handles them according to the configured AlteredPolicy. Hashes
+// are only updated if db is not nil. Nothing is checked with WithSkipHashCheck.
+func (m *Migrator) checkMigrations(db execer, ctx context.Context, paths []string, knownMigrations migrationRows) error {
+	if m.options.skipHashCheck {
+		return nil
+	}
+
+	err := m.checkIfMigrationsAreAltered(db, ctx, paths, knownMigrations)
+	if errors.Is(err, ErrMigrationFileChanged) {
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("check migrations: %w", err)
 	}
 
 	return nil
 }
 
-func checkIfMigrationsAreAltered(migrations embed.FS, knownMigrations []migrationRow) fs.WalkDirFunc {
-	return func(path string, d fs.DirEntry, err error) error {
+func (m *Migrator) checkIfMigrationsAreAltered(db execer, ctx context.Context, paths []string, knownMigrations migrationRows) error {
+	// The files are hashed concurrently up front, while the results are
+	// handled in order, as they may write to db.
+	hashes := m.hashAppliedMigrations(paths, knownMigrations)
+	for i, migrationPath := range paths {
+		migration, ok := m.findMigration(knownMigrations, migrationPath)
+		if !ok || !migration.IsApplied {
+			continue
+		}
+
+		currentHash, unchanged, err := hashes[i].hash, hashes[i].unchanged, hashes[i].err
 		if err != nil {
-			return fmt.Errorf("walk func errored: %w", err)
+			return err
 		}
 
-		if d.IsDir() {
-			return nil
+		if unchanged {
+			if currentHash != migration.MigrationHash && db != nil {
+				// The stored hash is a legacy one, so it is replaced by the
+				// current hash of the same content.
+				err = m.updateMigrationHash(db, ctx, migration.MigrationName, currentHash)
+				if err != nil {
+					return err
+				}
+				m.options.logger.DebugContext(ctx, "upgraded legacy migration hash", "migration", migration.MigrationName)
+			}
+			continue
 		}
 
-		migration, ok := findMigrationByName(knownMigrations, d.Name())
-		if !ok || !migration.IsApplied {
-			return nil
+		logger := m.options.logger.With("migration", migration.MigrationName, "stored_hash", migration.MigrationHash, "current_hash", currentHash)
+		switch m.options.onAltered {
+		case AlteredWarn:
+			logger.WarnContext(ctx, "migration has been altered")
+		case AlteredUpdate:
+			if db == nil {
+				logger.WarnContext(ctx, "migration has been altered")
+				continue
+			}
+			err = m.updateMigrationHash(db, ctx, migration.MigrationName, currentHash)
+			if err != nil {
+				return err
+			}
+			logger.WarnContext(ctx, "updated hash of altered migration")
+		default:
+			return alteredMigrationError(migration, currentHash)
 		}
+	}
 
-		readBytes, err := migrations.ReadFile(path)
+	return nil
+}
+
+// connect validates the options, checks out a connection and ensures the
+// migrations table exists. The caller must close the connection.
+func (m *Migrator) connect(ctx context.Context) (*sql.Conn, error) {
+	err := m.options.validate()
+	if err != nil {
+		return nil, err
+	}
+
+	var conn *sql.Conn
+	err = m.retryConnect(ctx, func() error {
+		attemptConn, err := m.openConn(ctx)
 		if err != nil {
-			return fmt.Errorf("read migration file %q: %w", d.Name(), err)
+			return err
 		}
 
-		migrationHash := hashFile(readBytes)
-
-		if migrationHash != migration.MigrationHash {
-			return fmt.Errorf("migration %q has been altered", d.Name())
+		err = m.createTable(attemptConn, ctx)
+		if err != nil {
+			m.closeConn(attemptConn)
+			return err
 		}
 
+		conn = attemptConn
 		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return conn, nil
+}
+
+func (m *Migrator) conn(ctx context.Context) (*sql.Conn, error) {
+	err := m.options.validate()
+	if err != nil {
+		return nil, err
+	}
+
+	var conn *sql.Conn
+	err = m.retryConnect(ctx, func() error {
+		conn, err = m.openConn(ctx)
+		return err
+	})
+	if err != nil {
+		return nil, err
 	}
+
+	return conn, nil
 }
 
-func handleMigration(conn *sql.Conn, ctx context.Context, migrations embed.FS, knownMigrations []migrationRow) fs.WalkDirFunc {
-	return func(path string, dirEntry fs.DirEntry, err error) error {
+// openConn checks out a connection and selects the schema of WithSchema on
+// it, in a single attempt.
+func (m *Migrator) openConn(ctx context.Context) (*sql.Conn, error) {
+	conn, err := m.acquireConn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if m.options.schema != "" {
+		err = m.selectSchema(conn, ctx)
 		if err != nil {
-			return fmt.Errorf("walk func errored: %w", err)
+			m.closeConn(conn)
+			return nil, err
 		}
+	}
 
-		if dirEntry.IsDir() {
-			return nil
+	return conn, nil
+}
+
+func (m *Migrator) createTable(db querier, ctx context.Context) error {
+	if m.options.tableSchema != "" {
+		err := m.createTableSchema(db, ctx)
+		if err != nil {
+			return err
+		}
+	}
+
+	// A table of another tool that happens to have the same name is refused
+	// before CreateTableQuery gets to upgrade it.
+	existed, inspected := true, false
+	if _, ok := m.dialect().(TableInspector); ok || m.options.onBootstrap != nil {
+		exists, err := m.tableExists(db, ctx)
+		if err != nil {
+			return err
+		}
+		existed, inspected = exists, true
+	}
+	if inspected && existed {
+		err := m.checkForeignTable(db, ctx)
+		if err != nil {
+			return err
 		}
+	}
+
+	_, err := db.ExecContext(ctx, m.TableDDL())
+	if err != nil {
+		return fmt.Errorf("create migrations table: %w", err)
+	}
+
+	err = m.validateTable(db, ctx)
+	if err != nil {
+		return err
+	}
+
+	if !existed && m.options.onBootstrap != nil {
+		return m.bootstrapped(ctx)
+	}
+
+	return nil
+}
+
+// pendingMigration is a migration file that has not been applied yet.
+type pendingMigration struct {
+	path       string
+	name       string
+	content    []byte
+	directives []directive
+}
+
+func (m *Migrator) pendingMigrations(ctx context.Context, paths []string, knownMigrations migrationRows) ([]pendingMigration, error) {
+	var pending []pendingMigration
+	for _, migrationPath := range paths {
+		migrationName := m.recordedName(knownMigrations, migrationPath)
 
-		migration, ok := findMigrationByName(knownMigrations, dirEntry.Name())
+		migration, ok := m.findMigration(knownMigrations, migrationPath)
 		if ok {
 			if migration.IsDirty {
-				return ErrDirtyMigration
+				return nil, ErrDirtyMigration
 			}
 			if migration.IsApplied {
-				return nil
+				m.options.logger.DebugContext(ctx, "skipped already-applied migration", "migration", migrationName)
+				continue
+			}
+		}
+
+		readBytes, err := m.readMigration(migrationPath)
+		if err != nil {
+			return nil, fmt.Errorf("read migration file %q: %w", m.migrationFileName(migrationPath), err)
+		}
+
+		candidate := pendingMigration{
+			path:       migrationPath,
+			name:       migrationName,
+			content:    readBytes,
+			directives: parseDirectives(readBytes),
+		}
+		if !m.runsInEnv(candidate) {
+			// Skipped migrations are not recorded, so they are applied once
+			// the environment matches.
+			m.options.logger.DebugContext(ctx, "skipped migration of another environment", "migration", migrationName, "env", m.options.env)
+			continue
+		}
+		pending = append(pending, candidate)
+	}
+
+	return pending, nil
+}
+
+// applyMigrations applies the pending migrations in order and returns the
+// names of those that were applied. With a parallelism above one, consecutive
+// parallel-safe migrations are applied concurrently.
+func (m *Migrator) applyMigrations(conn *sql.Conn, ctx context.Context, pending []pendingMigration) ([]string, error) {
+	// Every instance of an application typically migrates on boot, when
+	// nothing is pending, so no transaction is started for an empty run.
+	if len(pending) == 0 {
+		return nil, nil
+	}
+	if m.options.transactionMode != NoTransaction && !m.transactionalDDL() {
+		m.options.logger.WarnContext(ctx, "running migrations outside transactions, as the database cannot roll back DDL", "dialect", fmt.Sprintf("%T", m.dialect()))
+	}
+	if m.transactionMode() == AllInOne {
+		return m.applyInTransaction(conn, ctx, pending)
+	}
+
+	var (
+		appliedMigrations []string
+		parallelism       = m.parallelism()
+	)
+	for start := 0; start < len(pending); {
+		end := start + 1
+		if parallelism > 1 && pending[start].isParallelSafe() {
+			for end < len(pending) && pending[end].isParallelSafe() {
+				end++
+			}
+		}
+
+		if end-start == 1 {
+			err := m.applyMigration(conn, ctx, pending[start])
+			if err != nil {
+				return appliedMigrations, err
+			}
+			appliedMigrations = append(appliedMigrations, pending[start].name)
+		} else {
+			applied, err := m.applyConcurrently(ctx, pending[start:end], parallelism)
+			appliedMigrations = append(appliedMigrations, applied...)
+			if err != nil {
+				return appliedMigrations, err
 			}
 		}
 
-		readBytes, err := migrations.ReadFile(path)
+		start = end
+	}
+
+	return appliedMigrations, m.postMigrationCheckInTransaction(conn, ctx)
+}
+
+func (m *Migrator) applyMigration(conn *sql.Conn, ctx context.Context, migration pendingMigration) error {
+	if m.transactionMode() == PerMigration && !migration.isNoTransaction() {
+		return m.applyMigrationInTransaction(conn, ctx, migration)
+	}
+
+	return m.executeMigration(conn, ctx, migration)
+}
+
+// executeMigration marks the migration dirty, executes it with its data files
+// and marks it applied, all through db.
+func (m *Migrator) executeMigration(db execer, ctx context.Context, migration pendingMigration) error {
+	var (
+		logger = m.options.logger.With("migration", migration.name)
+		start  = time.Now()
+	)
+	logger.InfoContext(ctx, "applying migration")
+
+	if m.options.beforeEach != nil {
+		err := callHook("before each", func() { m.options.beforeEach(migration.name) })
 		if err != nil {
-			return fmt.Errorf("read migration file %q: %w", dirEntry.Name(), err)
+			return fmt.Errorf("migration %q: %w", migration.name, err)
 		}
+	}
 
-		migrationHash := hashFile(readBytes)
+	err := m.runMigration(db, ctx, migration)
+	duration := time.Since(start)
 
-		err = upsertMigration(conn, ctx, migrationRow{
-			MigrationName: dirEntry.Name(),
-			MigrationHash: migrationHash,
-			IsApplied:     false,
-			IsDirty:       true,
-		})
+	if m.options.afterEach != nil {
+		hookErr := callHook("after each", func() { m.options.afterEach(migration.name, err, duration) })
+		if hookErr != nil && err == nil {
+			err = fmt.Errorf("migration %q: %w", migration.name, hookErr)
+		}
+	}
+	err = m.reportMetrics(migration.name, err, duration)
+
+	if err != nil {
+		logger.ErrorContext(ctx, "migration failed", "duration", duration, "error", err)
+		return err
+	}
+
+	logger.InfoContext(ctx, "applied migration", "duration", duration)
+	return nil
+}
+
+func (m *Migrator) runMigration(db execer, ctx context.Context, migration pendingMigration) error {
+	fileName := m.migrationFileName(migration.path)
+
+	dataFiles, err := parseDataFiles(migration.directives)
+	if err != nil {
+		return fmt.Errorf("migration %q: %w", fileName, err)
+	}
+
+	migrationHash, err := m.hashMigration(migration.content)
+	if err != nil {
+		return fmt.Errorf("hash migration %q: %w", fileName, err)
+	}
+
+	err = m.upsertMigration(db, ctx, migrationRow{
+		MigrationName: migration.name,
+		MigrationHash: migrationHash,
+		IsApplied:     false,
+		IsDirty:       true,
+		Description:   migration.description(),
+	})
+	if err != nil {
+		return err
+	}
+
+	start := time.Now()
+	err = m.executeBody(db, ctx, migration, dataFiles)
+	if err != nil {
+		return &MigrationError{Migration: migration.name, File: fileName, Phase: PhaseExecute, Err: err}
+	}
+
+	err = m.upsertMigration(db, ctx, migrationRow{
+		MigrationName: migration.name,
+		MigrationHash: migrationHash,
+		IsApplied:     true,
+		IsDirty:       false,
+		Description:   migration.description(),
+		AppliedBy:     m.options.appliedBy,
+		Duration:      time.Since(start),
+	})
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// executeBody executes the statements of a migration and loads its data
+// files, bounded by the per-migration timeout if one is configured.
+func (m *Migrator) executeBody(db execer, ctx context.Context, migration pendingMigration, dataFiles []dataFile) error {
+	execCtx := ctx
+	if m.options.perMigrationTimeout > 0 {
+		var cancel context.CancelFunc
+		execCtx, cancel = context.WithTimeout(ctx, m.options.perMigrationTimeout)
+		defer cancel()
+	}
+
+	err := m.executeStatements(db, execCtx, migration, dataFiles)
+	if err != nil && ctx.Err() == nil && errors.Is(execCtx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("exceeded per-migration timeout of %s: %w", m.options.perMigrationTimeout, err)
+	}
+
+	return err
+}
+
+func (m *Migrator) executeStatements(db execer, ctx context.Context, migration pendingMigration, dataFiles []dataFile) error {
+	var err error
+	if name, ok := goMigrationName(migration.path); ok {
+		err = m.runGoMigration(db, ctx, name)
+	} else {
+		err = m.execStatements(db, ctx, migration.content)
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, dataFile := range dataFiles {
+		err = m.loadDataFile(db, ctx, dataFile)
 		if err != nil {
 			return err
 		}
+	}
 
-		_, err = conn.ExecContext(ctx, string(readBytes))
+	return nil
+}
+
+// migrationFiles returns the paths of all up migrations, including registered
+// Go migrations, in the order they are applied, which is ascending by
+// version. Two migrations with the same name, e.g. in different directories,
+// are reported as ErrDuplicateMigrationName, and two with the same version as
+// ErrDuplicateMigrationVersion.
+func (m *Migrator) migrationFiles() ([]string, error) {
+	var (
+		paths    []string
+		versions = map[string]int64{}
+		names    = map[string]string{}
+	)
+	err := fs.WalkDir(m.migrations, ".", func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
-			return fmt.Errorf("execute migration %q: %w: %w", dirEntry.Name(), err, ErrMigrationFailed)
+			return fmt.Errorf("walk func errored: %w", err)
 		}
 
-		err = upsertMigration(conn, ctx, migrationRow{
-			MigrationName: dirEntry.Name(),
-			MigrationHash: migrationHash,
-			IsApplied:     true,
-			IsDirty:       false,
-		})
+		if !m.isUpMigration(path, d) {
+			return nil
+		}
+		matched, err := m.matchesFilter(path)
 		if err != nil {
 			return err
 		}
+		if !matched {
+			return nil
+		}
+
+		name := m.migrationName(path)
+		err = validateMigrationName(name)
+		if err != nil {
+			return err
+		}
+		if other, ok := names[name]; ok {
+			return fmt.Errorf("%q and %q are both named %q: %w", other, path, name, ErrDuplicateMigrationName)
+		}
+		names[name] = path
+
+		version, err := parseVersion(m.migrationFileName(path))
+		if err != nil {
+			return err
+		}
+
+		versions[path] = version
+		paths = append(paths, path)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("walk migrations: %w", err)
+	}
+
+	for _, goPath := range m.goMigrationPaths() {
+		name := m.migrationName(goPath)
+		err = validateMigrationName(name)
+		if err != nil {
+			return nil, err
+		}
+		if other, ok := names[name]; ok {
+			return nil, fmt.Errorf("%q and Go migration %q are both named %q: %w", other, goPath, name, ErrDuplicateMigrationName)
+		}
+		names[name] = goPath
+
+		version, err := parseVersion(m.migrationFileName(goPath))
+		if err != nil {
+			return nil, err
+		}
+
+		versions[goPath] = version
+		paths = append(paths, goPath)
+	}
+
+	slices.SortStableFunc(paths, func(a, b string) int {
+		return cmp.Compare(versions[a], versions[b])
+	})
+	resolved := paths[:0]
+	for _, migrationPath := range paths {
+		last := len(resolved) - 1
+		if last < 0 || versions[resolved[last]] != versions[migrationPath] {
+			resolved = append(resolved, migrationPath)
+			continue
+		}
+
+		resolved[last], err = m.resolveVersionConflict(resolved[last], migrationPath)
+		if err != nil {
+			return nil, err
+		}
+	}
 
+	return resolved, nil
+}
+
+// checkEmpty reports a run without a single migration, which usually means
+// the migrations filesystem is misconfigured, e.g. by a go:embed pattern that
+// matches nothing.
+func (m *Migrator) checkEmpty(ctx context.Context, paths []string) error {
+	if len(paths) > 0 {
 		return nil
 	}
+	if m.options.errorOnEmpty {
+		return ErrNoMigrations
+	}
+
+	m.options.logger.WarnContext(ctx, "no migrations found")
+	return nil
 }
 
-func upsertMigration(conn *sql.Conn, ctx context.Context, migration migrationRow) error {
+// checkMaxMigrations refuses a run with more migrations than the limit set
+// with WithMaxMigrations, which usually means a go:embed pattern matches far
+// more files than intended.
+func (m *Migrator) checkMaxMigrations(paths []string) error {
+	if m.options.maxMigrations == 0 || len(paths) <= m.options.maxMigrations {
+		return nil
+	}
+
+	return fmt.Errorf("found %d migrations, more than the maximum of %d: %w", len(paths), m.options.maxMigrations, ErrTooManyMigrations)
+}
+
+func isDownMigration(name string) bool {
+	return strings.HasSuffix(name, downMigrationSuffix)
+}
+
+func (m *Migrator) upsertMigration(db execer, ctx context.Context, migration migrationRow) error {
 	var (
-		query = `INSERT INTO migrations (migration_name, migration_hash, is_applied, is_dirty)
-			VALUES ($1, $2, $3, $4)
-			ON CONFLICT(migration_name) DO UPDATE SET
-			migration_hash = excluded.migration_hash,
-			is_applied = excluded.is_applied,
-			is_dirty = excluded.is_dirty`
+		query     = m.dialect().UpsertQuery(m.table())
+		appliedAt any
 	)
+	if migration.IsApplied {
+		appliedAt = m.options.clock().UTC()
+	}
 
-	_, err := conn.ExecContext(ctx, query, migration.MigrationName, migration.MigrationHash, migration.IsApplied, migration.IsDirty)
+	// Every column is written, so re-applying a migration replaces what an
+	// earlier run recorded instead of keeping it.
+	_, err := db.ExecContext(ctx, query,
+		migration.MigrationName,
+		migration.MigrationHash,
+		migration.IsApplied,
+		migration.IsDirty,
+		appliedAt,
+		nullString(migration.Description),
+		nullString(migration.AppliedBy),
+		migration.Duration.Milliseconds(),
+	)
 	if err != nil {
 		return fmt.Errorf("upsert migration: %w", err)
 	}
@@ -193,27 +771,75 @@ func upsertMigration(conn *sql.Conn, ctx context.Context, migration migrationRow
 	return nil
 }
 
-func getMigrationsKnownToDb(conn *sql.Conn, ctx context.Context) ([]migrationRow, error) {
-	rows, err := conn.QueryContext(ctx, "SELECT migration_name, migration_hash, is_applied, is_dirty FROM migrations")
+// nullString stores an empty string as NULL.
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+// migrationRows are the rows of the migrations table in the order they were
+// read, indexed by name so looking up the row of every migration file does
+// not scan all of them.
+type migrationRows struct {
+	rows   []migrationRow
+	byName map[string]int
+}
+
+func newMigrationRows(rows []migrationRow) migrationRows {
+	byName := make(map[string]int, len(rows))
+	for i, row := range rows {
+		byName[row.MigrationName] = i
+	}
+
+	return migrationRows{rows: rows, byName: byName}
+}
+
+// find returns the row of the migration recorded as name.
+func (r migrationRows) find(name string) (migrationRow, bool) {
+	i, ok := r.byName[name]
+	if !ok {
+		return migrationRow{}, false
+	}
+
+	return r.rows[i], true
+}
+
+func (m *Migrator) getMigrationsKnownToDb(db querier, ctx context.Context) (migrationRows, error) {
+	return m.queryMigrations(db, ctx, m.dialect().SelectQuery(m.table()))
+}
+
+// queryMigrations reads the rows selected by query, which selects the
+// columns in the order of migrationColumns.
+func (m *Migrator) queryMigrations(db querier, ctx context.Context, query string) (migrationRows, error) {
+	rows, err := db.QueryContext(ctx, query)
 	if err != nil {
-		return nil, fmt.Errorf("query migrations: %w", err)
+		return migrationRows{}, fmt.Errorf("query migrations: %w", err)
 	}
 	defer rows.Close()
 
 	var appliedMigrations []migrationRow
 	for rows.Next() {
-		var migration migrationRow
-		if err := rows.Scan(&migration.MigrationName, &migration.MigrationHash, &migration.IsApplied, &migration.IsDirty); err != nil {
-			return nil, fmt.Errorf("scan migration row: %w", err)
+		var (
+			migration   migrationRow
+			appliedAt   sql.NullTime
+			description sql.NullString
+			appliedBy   sql.NullString
+			durationMs  sql.NullInt64
+		)
+		if err := rows.Scan(&migration.MigrationName, &migration.MigrationHash, &migration.IsApplied, &migration.IsDirty, &appliedAt, &description, &appliedBy, &durationMs); err != nil {
+			return migrationRows{}, fmt.Errorf("scan migration row: %w", err)
 		}
+		migration.AppliedAt = appliedAt.Time
+		migration.Description = description.String
+		migration.AppliedBy = appliedBy.String
+		migration.Duration = time.Duration(durationMs.Int64) * time.Millisecond
 		appliedMigrations = append(appliedMigrations, migration)
 	}
 
-	return appliedMigrations, nil
+	return newMigrationRows(appliedMigrations), nil
 }
 
-func hasDirtyMigration(migrations []migrationRow) bool {
-	for _, migration := range migrations {
+func hasDirtyMigration(migrations migrationRows) bool {
+	for _, migration := range migrations.rows {
 		if migration.IsDirty {
 			return true
 		}
@@ -221,20 +847,12 @@ func hasDirtyMigration(migrations []migrationRow) bool {
 	return false
 }
 
-func findMigrationByName(migrations []migrationRow, name string) (migrationRow, bool) {
-	for _, migration := range migrations {
-		if migration.MigrationName == name {
-			return migration, true
-		}
+// alteredMigrationError reports an applied migration whose file no longer
+// matches the stored hash.
+func alteredMigrationError(migration migrationRow, currentHash string) error {
+	return &MigrationError{
+		Migration: migration.MigrationName,
+		Phase:     PhaseCheck,
+		Err:       fmt.Errorf("stored hash %s, current hash %s: %w", migration.MigrationHash, currentHash, ErrMigrationFileChanged),
 	}
-	return migrationRow{}, false
-}
-
-func hashFile(value any) string {
-	var (
-		sha = sha256.New()
-	)
-
-	sha.Write(fmt.Appendf(nil, "%v", value)) // Should this be marshaled instead?
-	return fmt.Sprintf("%x", sha.Sum(nil))
 }
@@ -0,0 +1,91 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+)
+
+// MigrateConn is like MigrateContext but applies the migrations on conn
+// instead of a connection from the pool, e.g. one that already has session
+// settings the migrations rely on. conn is left open for the caller to close.
+// With WithSchema, the schema stays selected on conn afterwards, e.g. as its
+// search_path on PostgreSQL, so the caller has to reset it if later
+// statements must not run in that schema.
+func (m *Migrator) MigrateConn(ctx context.Context, conn *sql.Conn) error {
+	err := m.options.validate()
+	if err != nil {
+		return err
+	}
+	err = m.VerifyLock()
+	if err != nil {
+		return err
+	}
+
+	if m.options.schema != "" {
+		err = m.selectSchema(conn, ctx)
+		if err != nil {
+			return err
+		}
+	}
+
+	unlock, err := m.lock(conn, ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	err = m.createTable(conn, ctx)
+	if err != nil {
+		return err
+	}
+
+	_, err = m.migrateConn(conn, ctx, math.MaxInt64)
+	return err
+}
+
+// MigrateTx applies all pending migrations in tx, so they can share a
+// transaction with other setup code. Nothing is committed: the caller commits
+// or rolls back tx, which is why the after commit hook is not called. The
+// configured transaction mode and parallelism are ignored, migrations with a
+// "-- migrate:no-transaction" directive are refused, and as the migration
+// lock belongs to a connection rather than a transaction, none is taken.
+func (m *Migrator) MigrateTx(ctx context.Context, tx *sql.Tx) error {
+	err := m.options.validate()
+	if err != nil {
+		return err
+	}
+	err = m.VerifyLock()
+	if err != nil {
+		return err
+	}
+
+	if m.options.schema != "" {
+		err = m.selectSchema(tx, ctx)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = m.createTable(tx, ctx)
+	if err != nil {
+		return err
+	}
+
+	_, err = m.migrateWith(tx, ctx, math.MaxInt64, func(pending []pendingMigration) ([]string, error) {
+		for _, migration := range pending {
+			if migration.isNoTransaction() {
+				return nil, fmt.Errorf("migration %q: %s directive cannot be used with MigrateTx", m.migrationFileName(migration.path), noTransactionDirective)
+			}
+		}
+
+		appliedMigrations, err := m.executeInTransaction(tx, ctx, pending)
+		if err != nil || len(appliedMigrations) == 0 {
+			return appliedMigrations, err
+		}
+
+		return appliedMigrations, m.postMigrationCheck(tx, ctx)
+	})
+	return err
+}
@@ -0,0 +1,67 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+)
+
+// MigrateN applies the next n pending migrations in order and leaves the rest
+// pending, e.g. to roll out one migration at a time and observe the result
+// in between. If fewer than n migrations are pending, it applies none of them
+// and returns nil, so a batch is never applied partially; Status tells how
+// many are left.
+func (m *Migrator) MigrateN(n int) error {
+	timeoutCtx, cancel := context.WithTimeout(context.Background(), m.options.migrationTimeout)
+	defer cancel()
+
+	return m.MigrateNContext(timeoutCtx, n)
+}
+
+// MigrateNContext is like MigrateN but uses ctx for every database call
+// instead of the configured migration timeout.
+func (m *Migrator) MigrateNContext(ctx context.Context, n int) error {
+	if n < 0 {
+		return fmt.Errorf("migrate n: n must not be negative, got %d", n)
+	}
+	if n == 0 {
+		return nil
+	}
+
+	err := m.VerifyLock()
+	if err != nil {
+		return err
+	}
+
+	conn, release, err := m.connectLocked(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	// The pending migrations are counted under the lock, so no other process
+	// can apply some of them in between.
+	knownMigrations, err := m.getMigrationsKnownToDb(conn, ctx)
+	if err != nil {
+		return err
+	}
+	paths, err := m.migrationFiles()
+	if err != nil {
+		return err
+	}
+	pending, err := m.pendingMigrations(ctx, paths, knownMigrations)
+	if err != nil {
+		return err
+	}
+	if len(pending) < n {
+		m.options.logger.InfoContext(ctx, "fewer migrations pending than requested", "pending", len(pending), "requested", n)
+		return nil
+	}
+
+	version, err := parseVersion(m.migrationFileName(pending[n-1].path))
+	if err != nil {
+		return err
+	}
+
+	_, err = m.migrateConn(conn, ctx, version)
+	return err
+}
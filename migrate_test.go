@@ -1,14 +1,34 @@
 package migrate_test
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/sha512"
 	"database/sql"
+	"database/sql/driver"
 	"embed"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing/fstest"
+	"time"
 
 	"testing"
 
+	_ "github.com/lib/pq" // PostgreSQL driver registered as "postgres"
 	"github.com/stretchr/testify/assert"
 	"github.com/theonewiththewrench/go-migrate"
 	"github.com/theonewiththewrench/go-migrate/test_data"
+	_ "modernc.org/sqlite" // SQLite driver for MIGRATE_TEST_DRIVER=sqlite
 )
 
 //go:embed test_data/two_files_no_error/*.sql
@@ -16,7 +36,8 @@ var noErrorsMigration embed.FS
 
 var (
 	// These two files have the same name, so they will be treated as the same file
-	// However, they are different and their hashes are different
+	// However, they are different and their hashes are different. They are
+	// embedded separately, as a single filesystem holding both is rejected.
 
 	//go:embed test_data/one_file_changing_content/*.sql
 	changingMigrations embed.FS // This is the original file
@@ -27,6 +48,320 @@ var (
 //go:embed test_data/one_file_invalid_sql/*
 var invalidMigration embed.FS
 
+//go:embed test_data/second_file_invalid_sql/*.sql
+var partiallyInvalidMigration embed.FS
+
+//go:embed test_data/data_file_migration/*.sql
+var dataFileMigration embed.FS
+
+//go:embed test_data/parallel_safe_migrations/*.sql
+var parallelSafeMigrations embed.FS
+
+//go:embed test_data/with_down_migrations/*.sql
+var downMigrations embed.FS
+
+// This file creates a PL/pgSQL function whose body contains semicolons.
+//
+//go:embed test_data/dollar_quoted_function/*.sql
+var dollarQuotedMigration embed.FS
+
+// "10_..." sorts before "2_..." lexically, but depends on it.
+//
+//go:embed test_data/numeric_versions/*.sql
+var numericVersionMigrations embed.FS
+
+// The second migration alters the table created by the first, so it only
+// succeeds if the 14-digit timestamp versions are applied in order.
+//
+//go:embed test_data/timestamp_versions/*.sql
+var timestampMigrations embed.FS
+
+// Each migration is a directory holding an up.sql and a down.sql.
+//
+//go:embed test_data/directory_layout
+var directoryLayoutMigrations embed.FS
+
+// The second migration is invalid and marked to run outside a transaction.
+//
+//go:embed test_data/no_transaction_migration/*.sql
+var noTransactionMigrations embed.FS
+
+// The second migration inserts a row, so the row count tells how often the
+// migrations were applied.
+//
+//go:embed test_data/concurrent_migrations/*.sql
+var concurrentMigrations embed.FS
+
+//go:embed test_data/multi_statement_migration/*.sql
+var multiStatementMigration embed.FS
+
+// Both contain the same SQL, but the file with a BOM also has trailing
+// whitespace.
+var (
+	//go:embed test_data/bom_migration/without_bom/*.sql
+	withoutBOMMigration embed.FS
+	//go:embed test_data/bom_migration/with_bom/*.sql
+	withBOMMigration embed.FS
+)
+
+// testDialect returns the dialect matching the database SetupTestDatabase
+// connects to.
+func testDialect() migrate.Dialect {
+	if os.Getenv("MIGRATE_TEST_DRIVER") == "sqlite" {
+		return migrate.SQLiteDialect{}
+	}
+	return migrate.PostgresDialect{}
+}
+
+// setupConcurrentTestDatabase is like migrate.SetupTestDatabase, but returns
+// a database that several connections can write to one after another, for
+// migrations applied in parallel. The in-memory SQLite database of
+// SetupTestDatabase only allows one connection, so SQLite gets a database
+// file that waits for the write lock instead.
+func setupConcurrentTestDatabase(t *testing.T) *sql.DB {
+	if os.Getenv("MIGRATE_TEST_DRIVER") != "sqlite" {
+		return migrate.SetupTestDatabase(t)
+	}
+
+	db, err := sql.Open("sqlite", "file:"+path.Join(t.TempDir(), "test.db")+"?_pragma=busy_timeout(5000)&_pragma=journal_mode(wal)")
+	if err != nil {
+		t.Fatalf("failed to open sqlite database: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = db.Close()
+	})
+
+	return db
+}
+
+// splittingDialect executes migration bodies one statement at a time, like a
+// dialect for a driver that only accepts a single statement per query.
+type splittingDialect struct {
+	migrate.Dialect
+	statements int
+}
+
+func (d *splittingDialect) ExecBody(ctx context.Context, db migrate.Execer, body string) error {
+	for _, statement := range migrate.SplitStatements(body) {
+		_, err := db.ExecContext(ctx, statement)
+		if err != nil {
+			return err
+		}
+		d.statements++
+	}
+	return nil
+}
+
+type spyDialect struct {
+	migrate.Dialect
+	upserts int
+}
+
+func (d *spyDialect) UpsertQuery(table string) string {
+	d.upserts++
+	return d.Dialect.UpsertQuery(table)
+}
+
+// nonTransactionalDDLDialect stands in for a database such as MySQL, which
+// cannot roll back DDL.
+type nonTransactionalDDLDialect struct {
+	migrate.Dialect
+}
+
+func (nonTransactionalDDLDialect) TransactionalDDL() bool {
+	return false
+}
+
+// writingSelectDialect inserts into read_only_probe whenever it reads the
+// migrations table, which PostgreSQL refuses in a read-only transaction.
+type writingSelectDialect struct {
+	migrate.PostgresDialect
+}
+
+func (d writingSelectDialect) SelectQuery(table string) string {
+	return "WITH probe AS (INSERT INTO read_only_probe DEFAULT VALUES RETURNING 1) " + d.PostgresDialect.SelectQuery(table)
+}
+
+type spyMetrics struct {
+	applied []string
+	failed  []string
+}
+
+func (m *spyMetrics) OnMigrationApplied(name string, duration time.Duration) {
+	m.applied = append(m.applied, name)
+}
+
+func (m *spyMetrics) OnMigrationFailed(name string, err error) {
+	m.failed = append(m.failed, name)
+}
+
+// unreachableConnector fails every connection attempt, like a database that
+// has not started yet.
+type unreachableConnector struct {
+	attempts int
+}
+
+func (c *unreachableConnector) Connect(context.Context) (driver.Conn, error) {
+	c.attempts++
+	return nil, fmt.Errorf("connection refused")
+}
+
+func (c *unreachableConnector) Driver() driver.Driver {
+	return nil
+}
+
+// failingStatementConnector connects right away but fails every statement
+// with err, like a database that drops connections while it starts.
+type failingStatementConnector struct {
+	err        error
+	statements int
+}
+
+func (c *failingStatementConnector) Connect(context.Context) (driver.Conn, error) {
+	return &failingStatementConn{connector: c}, nil
+}
+
+func (c *failingStatementConnector) Driver() driver.Driver {
+	return nil
+}
+
+type failingStatementConn struct {
+	connector *failingStatementConnector
+}
+
+func (c *failingStatementConn) Prepare(string) (driver.Stmt, error) {
+	c.connector.statements++
+	return nil, c.connector.err
+}
+
+func (c *failingStatementConn) Close() error {
+	return nil
+}
+
+func (c *failingStatementConn) Begin() (driver.Tx, error) {
+	return nil, c.connector.err
+}
+
+// walkCountingFS counts how often the migrations are walked.
+type walkCountingFS struct {
+	fstest.MapFS
+	walks int
+}
+
+func (f *walkCountingFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if name == "." {
+		f.walks++
+	}
+	return f.MapFS.ReadDir(name)
+}
+
+// failRecordingT records that a test failed instead of stopping it, leaving
+// logging and cleanups to the real test.
+type failRecordingT struct {
+	*testing.T
+	failed bool
+}
+
+func (f *failRecordingT) FailNow() {
+	f.failed = true
+}
+
+func TestSetupMigratedDatabase(t *testing.T) {
+	t.Run("returns a database with the migrations applied", func(t *testing.T) {
+		// Act
+		db := migrate.SetupMigratedDatabase(t, noErrorsMigration)
+
+		// Assert
+		assert.True(t, test_data.NewRepo(db).GetMigrationByName("002_more_test.sql").IsApplied)
+	})
+
+	t.Run("fails the test when a migration fails", func(t *testing.T) {
+		// Arrange
+		var (
+			recorder = &failRecordingT{T: t}
+		)
+
+		// Act
+		migrate.SetupMigratedDatabase(recorder, partiallyInvalidMigration)
+
+		// Assert
+		assert.True(t, recorder.failed)
+	})
+}
+
+func TestNewValidatedMigrator(t *testing.T) {
+	t.Run("returns a migrator for valid options", func(t *testing.T) {
+		// Arrange
+		var (
+			db         = migrate.SetupTestDatabase(t)
+			migrations = fstest.MapFS{
+				"001_test.sql": {Data: []byte("CREATE TABLE test (id INT PRIMARY KEY);")},
+			}
+		)
+
+		// Act
+		migrator, err := migrate.NewValidatedMigrator(db, migrations, migrate.WithTableName("schema_migrations"))
+
+		// Assert
+		assert.NoError(t, err)
+		assert.NoError(t, migrator.Migrate())
+	})
+
+	t.Run("rejects a zero migration timeout", func(t *testing.T) {
+		// Act
+		migrator, err := migrate.NewValidatedMigrator(nil, fstest.MapFS{}, migrate.WithMigrationTimeout(0))
+
+		// Assert
+		assert.ErrorContains(t, err, "invalid migration timeout")
+		assert.Nil(t, migrator)
+	})
+
+	t.Run("rejects a parallelism below one", func(t *testing.T) {
+		// Act
+		migrator, err := migrate.NewValidatedMigrator(nil, fstest.MapFS{}, migrate.WithParallelism(0))
+
+		// Assert
+		assert.ErrorContains(t, err, "invalid parallelism")
+		assert.Nil(t, migrator)
+	})
+
+	t.Run("rejects negative connect attempts", func(t *testing.T) {
+		// Act
+		migrator, err := migrate.NewValidatedMigrator(nil, fstest.MapFS{}, migrate.WithConnectRetry(-1, time.Second))
+
+		// Assert
+		assert.ErrorContains(t, err, "invalid connect attempts")
+		assert.Nil(t, migrator)
+	})
+
+	t.Run("rejects a negative connect backoff", func(t *testing.T) {
+		// Act
+		migrator, err := migrate.NewValidatedMigrator(nil, fstest.MapFS{}, migrate.WithConnectRetry(3, -time.Second))
+
+		// Assert
+		assert.ErrorContains(t, err, "invalid connect backoff")
+		assert.Nil(t, migrator)
+	})
+
+	t.Run("rejects a negative maximum of migrations", func(t *testing.T) {
+		// Act
+		migrator, err := migrate.NewValidatedMigrator(nil, fstest.MapFS{}, migrate.WithMaxMigrations(-1))
+
+		// Assert
+		assert.ErrorContains(t, err, "invalid maximum of migrations")
+		assert.Nil(t, migrator)
+	})
+
+	t.Run("rejects an invalid table name", func(t *testing.T) {
+		// Act
+		migrator, err := migrate.NewValidatedMigrator(nil, fstest.MapFS{}, migrate.WithTableName(""))
+
+		// Assert
+		assert.ErrorContains(t, err, "invalid table name")
+		assert.Nil(t, migrator)
+	})
+}
+
 func TestMigrate(t *testing.T) {
 	var newRepo = func(db *sql.DB) *test_data.TestRepo {
 		return test_data.NewRepo(db)
@@ -34,7 +369,7 @@ func TestMigrate(t *testing.T) {
 
 	t.Run("Migrate", func(t *testing.T) {
 		var (
-			sut = func(db *sql.DB, migrations embed.FS) error {
+			sut = func(db *sql.DB, migrations fs.FS) error {
 				return migrate.NewMigrator(db, migrations).Migrate()
 			}
 		)
@@ -56,68 +391,4221 @@ func TestMigrate(t *testing.T) {
 			for _, migration := range migrations {
 				assert.True(t, migration.IsApplied)
 				assert.False(t, migration.IsDirty)
+				assert.False(t, migration.AppliedAt.IsZero())
 			}
 		})
 
-		t.Run("can call migrate multiple times", func(t *testing.T) {
-			db := migrate.SetupTestDatabase(t)
+		t.Run("successfully migrate from any fs.FS", func(t *testing.T) {
+			// Arrange
+			var (
+				db         = migrate.SetupTestDatabase(t)
+				repo       = newRepo(db)
+				migrations = fstest.MapFS{
+					"001_test.sql": {Data: []byte("CREATE TABLE IF NOT EXISTS test (id INT PRIMARY KEY);")},
+				}
+			)
 
-			err := sut(db, noErrorsMigration)
+			// Act
+			err := sut(db, migrations)
+
+			// Assert
 			assert.NoError(t, err)
+			assert.True(t, repo.GetMigrationByName("001_test.sql").IsApplied)
+		})
 
-			err = sut(db, noErrorsMigration)
+		t.Run("asks to confirm only destructive migrations", func(t *testing.T) {
+			// Arrange
+			var (
+				db         = migrate.SetupTestDatabase(t)
+				repo       = newRepo(db)
+				migrations = fstest.MapFS{
+					"001_test.sql": {Data: []byte("CREATE TABLE test (id INT PRIMARY KEY);")},
+					"002_drop.sql": {Data: []byte("-- migrate:destructive\nDROP TABLE test;")},
+				}
+				asked   []string
+				confirm = func(name string) (bool, error) {
+					asked = append(asked, name)
+					return true, nil
+				}
+			)
+
+			// Act
+			err := migrate.NewMigrator(db, migrations, migrate.WithConfirm(confirm)).Migrate()
+
+			// Assert
 			assert.NoError(t, err)
+			assert.Equal(t, []string{"002_drop.sql"}, asked)
+			assert.True(t, repo.GetMigrationByName("002_drop.sql").IsApplied)
 		})
 
-		t.Run("should error when migration file changed", func(t *testing.T) {
+		t.Run("should error before applying anything when a destructive migration is declined", func(t *testing.T) {
 			// Arrange
 			var (
-				db = migrate.SetupTestDatabase(t)
+				db         = migrate.SetupTestDatabase(t)
+				repo       = newRepo(db)
+				migrations = fstest.MapFS{
+					"001_test.sql": {Data: []byte("CREATE TABLE test (id INT PRIMARY KEY);")},
+					"002_drop.sql": {Data: []byte("-- migrate:destructive\nDROP TABLE test;")},
+				}
+				confirm = func(name string) (bool, error) {
+					return false, nil
+				}
 			)
-			err := sut(db, changingMigrations) // Setup the initial state
 
 			// Act
-			err = sut(db, changingMigrationsChanged) // Attempt to migrate with changed file
+			err := migrate.NewMigrator(db, migrations, migrate.WithConfirm(confirm)).Migrate()
+
+			// Assert
+			assert.ErrorIs(t, err, migrate.ErrMigrationNotConfirmed)
+			assert.ErrorContains(t, err, "002_drop.sql")
+			rows, err := repo.GetAllMigrations()
+			assert.NoError(t, err)
+			assert.Empty(t, rows)
+		})
+
+		t.Run("should error when confirming a destructive migration fails", func(t *testing.T) {
+			// Arrange
+			var (
+				db         = migrate.SetupTestDatabase(t)
+				migrations = fstest.MapFS{
+					"001_drop.sql": {Data: []byte("-- migrate:destructive\nDROP TABLE IF EXISTS test;")},
+				}
+				confirm = func(name string) (bool, error) {
+					return false, fmt.Errorf("no operator available")
+				}
+			)
+
+			// Act
+			err := migrate.NewMigrator(db, migrations, migrate.WithConfirm(confirm)).Migrate()
+
+			// Assert
+			assert.ErrorContains(t, err, `confirm migration "001_drop.sql": no operator available`)
+		})
+
+		t.Run("orders the migrations of additional filesystems together", func(t *testing.T) {
+			// Arrange
+			var (
+				db         = migrate.SetupTestDatabase(t)
+				repo       = newRepo(db)
+				migrations = fstest.MapFS{
+					"001_app.sql": {Data: []byte("CREATE TABLE test (id INT PRIMARY KEY);")},
+					"003_app.sql": {Data: []byte("INSERT INTO test (id) VALUES (3);")},
+				}
+				library = fstest.MapFS{
+					"002_library.sql": {Data: []byte("INSERT INTO test (id) VALUES (2);")},
+				}
+				count int
+			)
+
+			// Act
+			err := migrate.NewMigrator(db, migrations, migrate.WithAdditionalFS(library)).Migrate()
+
+			// Assert
+			assert.NoError(t, err)
+			assert.True(t, repo.GetMigrationByName("002_library.sql").IsApplied)
+			assert.True(t, repo.GetMigrationByName("003_app.sql").IsApplied)
+			assert.NoError(t, db.QueryRow("SELECT COUNT(*) FROM test").Scan(&count))
+			assert.Equal(t, 2, count)
+		})
+
+		t.Run("applies a migration found in several filesystems once", func(t *testing.T) {
+			// Arrange
+			var (
+				db         = migrate.SetupTestDatabase(t)
+				repo       = newRepo(db)
+				migrations = fstest.MapFS{
+					"001_test.sql": {Data: []byte("CREATE TABLE test (id INT PRIMARY KEY);")},
+				}
+				library = fstest.MapFS{
+					"001_test.sql": {Data: []byte("CREATE TABLE test (id INT PRIMARY KEY);")},
+				}
+			)
+
+			// Act
+			err := migrate.NewMigrator(db, migrations, migrate.WithAdditionalFS(library)).Migrate()
+
+			// Assert
+			assert.NoError(t, err)
+			assert.True(t, repo.GetMigrationByName("001_test.sql").IsApplied)
+		})
+
+		t.Run("should error when a migration differs between filesystems", func(t *testing.T) {
+			// Arrange
+			var (
+				db         = migrate.SetupTestDatabase(t)
+				migrations = fstest.MapFS{
+					"001_test.sql": {Data: []byte("CREATE TABLE test (id INT PRIMARY KEY);")},
+				}
+				library = fstest.MapFS{
+					"001_test.sql": {Data: []byte("CREATE TABLE library (id INT PRIMARY KEY);")},
+				}
+			)
+
+			// Act
+			err := migrate.NewMigrator(db, migrations, migrate.WithAdditionalFS(library)).Migrate()
+
+			// Assert
+			assert.ErrorIs(t, err, migrate.ErrDuplicateMigrationName)
+		})
+
+		t.Run("should error when filesystems share a version", func(t *testing.T) {
+			// Arrange
+			var (
+				db         = migrate.SetupTestDatabase(t)
+				migrations = fstest.MapFS{
+					"001_app.sql": {Data: []byte("CREATE TABLE test (id INT PRIMARY KEY);")},
+				}
+				library = fstest.MapFS{
+					"001_library.sql": {Data: []byte("CREATE TABLE library (id INT PRIMARY KEY);")},
+				}
+			)
+
+			// Act
+			err := migrate.NewMigrator(db, migrations, migrate.WithAdditionalFS(library)).Migrate()
+
+			// Assert
+			assert.ErrorIs(t, err, migrate.ErrDuplicateMigrationVersion)
+		})
+
+		t.Run("uses the migration of the first filesystem with VersionConflictFirst", func(t *testing.T) {
+			// Arrange
+			var (
+				db         = migrate.SetupTestDatabase(t)
+				repo       = newRepo(db)
+				migrations = fstest.MapFS{
+					"001_base.sql": {Data: []byte("CREATE TABLE base (id INT PRIMARY KEY);")},
+				}
+				override = fstest.MapFS{
+					"001_override.sql": {Data: []byte("CREATE TABLE override (id INT PRIMARY KEY);")},
+				}
+			)
+
+			// Act
+			err := migrate.NewMigrator(db, migrations, migrate.WithAdditionalFS(override), migrate.WithVersionConflictPolicy(migrate.VersionConflictFirst)).Migrate()
 
 			// Assert
+			assert.NoError(t, err)
+			assert.True(t, repo.GetMigrationByName("001_base.sql").IsApplied)
+			assert.Empty(t, repo.GetMigrationByName("001_override.sql").MigrationName)
+			_, err = db.Exec("SELECT * FROM override")
 			assert.Error(t, err)
-			assert.ErrorIs(t, err, migrate.ErrMigrationFileChanged)
 		})
 
-		t.Run("should error when migration has invalid sql", func(t *testing.T) {
+		t.Run("uses the migration of the last filesystem with VersionConflictLast", func(t *testing.T) {
 			// Arrange
 			var (
-				db   = migrate.SetupTestDatabase(t)
-				repo = newRepo(db)
+				db         = migrate.SetupTestDatabase(t)
+				repo       = newRepo(db)
+				migrations = fstest.MapFS{
+					"001_base.sql": {Data: []byte("CREATE TABLE base (id INT PRIMARY KEY);")},
+				}
+				override = fstest.MapFS{
+					"001_override.sql": {Data: []byte("CREATE TABLE override (id INT PRIMARY KEY);")},
+				}
 			)
 
 			// Act
-			err := sut(db, invalidMigration)
+			err := migrate.NewMigrator(db, migrations, migrate.WithAdditionalFS(override), migrate.WithVersionConflictPolicy(migrate.VersionConflictLast)).Migrate()
 
 			// Assert
+			assert.NoError(t, err)
+			assert.True(t, repo.GetMigrationByName("001_override.sql").IsApplied)
+			assert.Empty(t, repo.GetMigrationByName("001_base.sql").MigrationName)
+			_, err = db.Exec("SELECT * FROM base")
 			assert.Error(t, err)
-			assert.ErrorIs(t, err, migrate.ErrMigrationFailed)
-			migrations, err := repo.GetAllMigrations()
+		})
+
+		t.Run("executes and hashes the overriding content of a file at the same path with VersionConflictLast", func(t *testing.T) {
+			// Arrange
+			var (
+				db         = migrate.SetupTestDatabase(t)
+				repo       = newRepo(db)
+				migrations = fstest.MapFS{
+					"001_test.sql": {Data: []byte("CREATE TABLE base (id INT PRIMARY KEY);")},
+				}
+				override = fstest.MapFS{
+					"001_test.sql": {Data: []byte("CREATE TABLE override (id INT PRIMARY KEY);")},
+				}
+			)
+
+			// Act
+			err := migrate.NewMigrator(db, migrations, migrate.WithAdditionalFS(override), migrate.WithVersionConflictPolicy(migrate.VersionConflictLast)).Migrate()
+
+			// Assert
 			assert.NoError(t, err)
-			assert.Len(t, migrations, 1)
-			assert.False(t, migrations[0].IsApplied)
-			assert.True(t, migrations[0].IsDirty)
+			_, err = db.Exec("SELECT * FROM override")
+			assert.NoError(t, err)
+			assert.Equal(t, migrate.SHA256Hasher(override["001_test.sql"].Data), repo.GetMigrationByName("001_test.sql").MigrationHash)
 		})
 
-		t.Run("should error when dirty migration exists", func(t *testing.T) {
+		t.Run("should error when one filesystem has two migrations with the same version despite a policy", func(t *testing.T) {
 			// Arrange
 			var (
-				db = migrate.SetupTestDatabase(t)
+				db         = migrate.SetupTestDatabase(t)
+				migrations = fstest.MapFS{
+					"001_a.sql": {Data: []byte("CREATE TABLE a (id INT PRIMARY KEY);")},
+					"001_b.sql": {Data: []byte("CREATE TABLE b (id INT PRIMARY KEY);")},
+				}
+				override = fstest.MapFS{
+					"002_c.sql": {Data: []byte("CREATE TABLE c (id INT PRIMARY KEY);")},
+				}
 			)
 
-			_ = sut(db, invalidMigration)
+			// Act
+			err := migrate.NewMigrator(db, migrations, migrate.WithAdditionalFS(override), migrate.WithVersionConflictPolicy(migrate.VersionConflictLast)).Migrate()
+
+			// Assert
+			assert.ErrorIs(t, err, migrate.ErrDuplicateMigrationVersion)
+		})
+
+		t.Run("walks the migrations once per run", func(t *testing.T) {
+			// Arrange
+			var (
+				db         = migrate.SetupTestDatabase(t)
+				migrations = &walkCountingFS{MapFS: fstest.MapFS{
+					"001_test.sql": {Data: []byte("CREATE TABLE test (id INT PRIMARY KEY);")},
+					"002_more.sql": {Data: []byte("CREATE TABLE more_test (id INT PRIMARY KEY);")},
+				}}
+				migrator = migrate.NewMigrator(db, migrations, migrate.WithOutOfOrder(migrate.OutOfOrderWarn))
+			)
+			err := migrator.MigrateTo("1")
+			assert.NoError(t, err)
+			migrations.walks = 0
 
 			// Act
-			err := sut(db, invalidMigration)
+			_, err = migrator.MigrateResult()
 
 			// Assert
-			assert.Error(t, err)
-			assert.ErrorIs(t, err, migrate.ErrDirtyMigration)
+			assert.NoError(t, err)
+			assert.Equal(t, 1, migrations.walks)
+		})
+
+		t.Run("ignores files without the migration extension", func(t *testing.T) {
+			// Arrange
+			var (
+				db         = migrate.SetupTestDatabase(t)
+				repo       = newRepo(db)
+				migrations = fstest.MapFS{
+					"001_test.sql": {Data: []byte("CREATE TABLE test (id INT PRIMARY KEY);")},
+					"README.md":    {Data: []byte("# Migrations")},
+					".gitkeep":     {Data: []byte{}},
+				}
+			)
+
+			// Act
+			err := migrate.NewMigrator(db, migrations).Migrate()
+
+			// Assert
+			assert.NoError(t, err)
+			known, err := repo.GetAllMigrations()
+			assert.NoError(t, err)
+			assert.Len(t, known, 1)
+			assert.True(t, repo.GetMigrationByName("001_test.sql").IsApplied)
 		})
+
+		t.Run("applies files with a custom extension", func(t *testing.T) {
+			// Arrange
+			var (
+				db         = migrate.SetupTestDatabase(t)
+				repo       = newRepo(db)
+				migrations = fstest.MapFS{
+					"001_test.pgsql":    {Data: []byte("CREATE TABLE test (id INT PRIMARY KEY);")},
+					"001_test.down.sql": {Data: []byte("DROP TABLE test;")},
+					"002_ignored.sql":   {Data: []byte("NOT SQL")},
+				}
+				migrator = migrate.NewMigrator(db, migrations, migrate.WithExtension(".pgsql"))
+			)
+
+			// Act
+			err := migrator.Migrate()
+
+			// Assert
+			assert.NoError(t, err)
+			known, err := repo.GetAllMigrations()
+			assert.NoError(t, err)
+			assert.Len(t, known, 1)
+			assert.True(t, repo.GetMigrationByName("001_test.pgsql").IsApplied)
+			assert.NoError(t, migrator.Rollback(1))
+		})
+
+		t.Run("retries failed connections", func(t *testing.T) {
+			// Arrange
+			var (
+				connector = &unreachableConnector{}
+				db        = sql.OpenDB(connector)
+				migrator  = migrate.NewMigrator(db, noErrorsMigration,
+					migrate.WithDialect(migrate.PostgresDialect{}),
+					migrate.WithConnectRetry(3, time.Millisecond),
+				)
+			)
+			t.Cleanup(func() { _ = db.Close() })
+
+			// Act
+			err := migrator.Migrate()
+
+			// Assert
+			assert.ErrorContains(t, err, "connection refused")
+			assert.Equal(t, 3, connector.attempts)
+		})
+
+		t.Run("waits between connection attempts without a backoff", func(t *testing.T) {
+			// Arrange
+			var (
+				connector = &unreachableConnector{}
+				db        = sql.OpenDB(connector)
+				migrator  = migrate.NewMigrator(db, noErrorsMigration,
+					migrate.WithDialect(migrate.PostgresDialect{}),
+					migrate.WithConnectRetry(3, 0),
+				)
+				start = time.Now()
+			)
+			t.Cleanup(func() { _ = db.Close() })
+
+			// Act
+			err := migrator.Migrate()
+
+			// Assert
+			assert.ErrorContains(t, err, "connection refused")
+			assert.Equal(t, 3, connector.attempts)
+			assert.GreaterOrEqual(t, time.Since(start), 30*time.Millisecond)
+		})
+
+		t.Run("retries creating the migrations table on a connection error", func(t *testing.T) {
+			// Arrange
+			var (
+				connector = &failingStatementConnector{err: io.ErrUnexpectedEOF}
+				db        = sql.OpenDB(connector)
+				migrator  = migrate.NewMigrator(db, noErrorsMigration,
+					migrate.WithDialect(migrate.SQLiteDialect{}),
+					migrate.WithConnectRetry(3, time.Millisecond),
+				)
+			)
+			t.Cleanup(func() { _ = db.Close() })
+
+			// Act
+			err := migrator.Migrate()
+
+			// Assert
+			assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+			assert.Equal(t, 3, connector.statements)
+		})
+
+		t.Run("does not retry creating the migrations table on a SQL error", func(t *testing.T) {
+			// Arrange
+			var (
+				connector = &failingStatementConnector{err: fmt.Errorf("syntax error")}
+				db        = sql.OpenDB(connector)
+				migrator  = migrate.NewMigrator(db, noErrorsMigration,
+					migrate.WithDialect(migrate.SQLiteDialect{}),
+					migrate.WithConnectRetry(3, time.Millisecond),
+				)
+			)
+			t.Cleanup(func() { _ = db.Close() })
+
+			// Act
+			err := migrator.Migrate()
+
+			// Assert
+			assert.ErrorContains(t, err, "syntax error")
+			assert.Equal(t, 1, connector.statements)
+		})
+
+		t.Run("calls the bootstrap hook only when creating the migrations table", func(t *testing.T) {
+			// Arrange
+			var (
+				db           = migrate.SetupTestDatabase(t)
+				bootstrapped []string
+				migrator     = migrate.NewMigrator(db, noErrorsMigration, migrate.WithOnBootstrap(func(table string) {
+					bootstrapped = append(bootstrapped, table)
+				}))
+			)
+			err := migrator.Migrate()
+			assert.NoError(t, err)
+
+			// Act
+			err = migrator.Migrate()
+
+			// Assert
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"migrations"}, bootstrapped)
+		})
+
+		t.Run("applies only migrations of the configured environment", func(t *testing.T) {
+			// Arrange
+			var (
+				db         = migrate.SetupTestDatabase(t)
+				repo       = newRepo(db)
+				migrations = fstest.MapFS{
+					"001_test.sql":  {Data: []byte("CREATE TABLE test (id INT PRIMARY KEY);")},
+					"002_seed.sql":  {Data: []byte("-- migrate:env test,dev\nINSERT INTO test (id) VALUES (1);")},
+					"003_audit.sql": {Data: []byte("-- migrate:env prod\nCREATE TABLE audit (id INT PRIMARY KEY);")},
+				}
+			)
+
+			// Act
+			err := migrate.NewMigrator(db, migrations, migrate.WithEnv("dev")).Migrate()
+
+			// Assert
+			assert.NoError(t, err)
+			migrationRows, err := repo.GetAllMigrations()
+			assert.NoError(t, err)
+			assert.Len(t, migrationRows, 2)
+			assert.True(t, repo.GetMigrationByName("001_test.sql").IsApplied)
+			assert.True(t, repo.GetMigrationByName("002_seed.sql").IsApplied)
+		})
+
+		t.Run("applies skipped migrations once the environment matches", func(t *testing.T) {
+			// Arrange
+			var (
+				db         = migrate.SetupTestDatabase(t)
+				repo       = newRepo(db)
+				migrations = fstest.MapFS{
+					"001_test.sql": {Data: []byte("CREATE TABLE test (id INT PRIMARY KEY);")},
+					"002_seed.sql": {Data: []byte("-- migrate:env test\nINSERT INTO test (id) VALUES (1);")},
+				}
+			)
+			err := migrate.NewMigrator(db, migrations).Migrate()
+			assert.NoError(t, err)
+			migrationRows, err := repo.GetAllMigrations()
+			assert.NoError(t, err)
+			assert.Len(t, migrationRows, 1)
+
+			// Act
+			err = migrate.NewMigrator(db, migrations, migrate.WithEnv("test")).Migrate()
+
+			// Assert
+			assert.NoError(t, err)
+			assert.True(t, repo.GetMigrationByName("002_seed.sql").IsApplied)
+		})
+
+		t.Run("can call migrate multiple times", func(t *testing.T) {
+			db := migrate.SetupTestDatabase(t)
+
+			err := sut(db, noErrorsMigration)
+			assert.NoError(t, err)
+
+			err = sut(db, noErrorsMigration)
+			assert.NoError(t, err)
+		})
+
+		t.Run("should error when migration file changed", func(t *testing.T) {
+			// Arrange
+			var (
+				db = migrate.SetupTestDatabase(t)
+			)
+			err := sut(db, changingMigrations) // Setup the initial state
+
+			// Act
+			err = sut(db, changingMigrationsChanged) // Attempt to migrate with changed file
+
+			// Assert
+			assert.Error(t, err)
+			assert.ErrorIs(t, err, migrate.ErrMigrationFileChanged)
+			assert.ErrorContains(t, err, `migration "001_test.sql" has been altered, stored hash `)
+			assert.ErrorContains(t, err, ", current hash ")
+		})
+
+		t.Run("applies a migration file with several statements", func(t *testing.T) {
+			// Arrange
+			var (
+				db    = migrate.SetupTestDatabase(t)
+				repo  = newRepo(db)
+				count int
+			)
+
+			// Act
+			err := migrate.NewMigrator(db, multiStatementMigration, migrate.WithDialect(testDialect())).Migrate()
+
+			// Assert
+			assert.NoError(t, err)
+			assert.True(t, repo.GetMigrationByName("001_multi_statement.sql").IsApplied)
+			err = db.QueryRow("SELECT COUNT(*) FROM test").Scan(&count)
+			assert.NoError(t, err)
+			assert.Equal(t, 2, count)
+		})
+
+		t.Run("executes migration bodies through a BodyExecutor dialect", func(t *testing.T) {
+			// Arrange
+			var (
+				db      = migrate.SetupTestDatabase(t)
+				repo    = newRepo(db)
+				dialect = &splittingDialect{Dialect: testDialect()}
+			)
+
+			// Act
+			err := migrate.NewMigrator(db, multiStatementMigration, migrate.WithDialect(dialect)).Migrate()
+
+			// Assert
+			assert.NoError(t, err)
+			assert.True(t, repo.GetMigrationByName("001_multi_statement.sql").IsApplied)
+			assert.Equal(t, 3, dialect.statements)
+		})
+
+		t.Run("executes down migration bodies through a BodyExecutor dialect", func(t *testing.T) {
+			// Arrange
+			var (
+				db       = migrate.SetupTestDatabase(t)
+				repo     = newRepo(db)
+				dialect  = &splittingDialect{Dialect: testDialect()}
+				migrator = migrate.NewMigrator(db, multiStatementMigration, migrate.WithDialect(dialect))
+			)
+			err := migrator.Migrate()
+			assert.NoError(t, err)
+
+			// Act
+			err = migrator.Rollback(1)
+
+			// Assert
+			assert.NoError(t, err)
+			assert.False(t, repo.GetMigrationByName("001_multi_statement.sql").IsApplied)
+			assert.Equal(t, 6, dialect.statements)
+		})
+
+		t.Run("prefers the statement splitter over a BodyExecutor dialect", func(t *testing.T) {
+			// Arrange
+			var (
+				db      = migrate.SetupTestDatabase(t)
+				dialect = &splittingDialect{Dialect: testDialect()}
+			)
+
+			// Act
+			err := migrate.NewMigrator(db, multiStatementMigration, migrate.WithDialect(dialect), migrate.WithStatementSplitter(migrate.SplitStatements)).Migrate()
+
+			// Assert
+			assert.NoError(t, err)
+			assert.Zero(t, dialect.statements)
+		})
+
+		t.Run("executes statements one at a time with a statement splitter", func(t *testing.T) {
+			// Arrange
+			var (
+				db         = migrate.SetupTestDatabase(t)
+				repo       = newRepo(db)
+				migrations = fstest.MapFS{
+					"001_test.sql": {Data: []byte("CREATE TABLE test (id INT PRIMARY KEY, name VARCHAR(100));\nINSERT INTO test (id, name) VALUES (1, 'a;b');\n")},
+				}
+				name string
+			)
+
+			// Act
+			err := migrate.NewMigrator(db, migrations, migrate.WithStatementSplitter(migrate.SplitStatements)).Migrate()
+
+			// Assert
+			assert.NoError(t, err)
+			assert.True(t, repo.GetMigrationByName("001_test.sql").IsApplied)
+			err = db.QueryRow("SELECT name FROM test WHERE id = 1").Scan(&name)
+			assert.NoError(t, err)
+			assert.Equal(t, "a;b", name)
+		})
+
+		t.Run("executes a function with a dollar quoted body", func(t *testing.T) {
+			if _, ok := testDialect().(migrate.PostgresDialect); !ok {
+				t.Skip("test database has no PL/pgSQL")
+			}
+
+			// Arrange
+			var (
+				db   = migrate.SetupTestDatabase(t)
+				repo = newRepo(db)
+				name string
+			)
+
+			// Act
+			err := migrate.NewMigrator(db, dollarQuotedMigration).Migrate()
+
+			// Assert
+			assert.NoError(t, err)
+			assert.True(t, repo.GetMigrationByName("001_function.sql").IsApplied)
+			err = db.QueryRow("SELECT name FROM test WHERE id = 1").Scan(&name)
+			assert.NoError(t, err)
+			assert.Equal(t, "a;b", name)
+		})
+
+		t.Run("executes a function with a dollar quoted body with a statement splitter", func(t *testing.T) {
+			if _, ok := testDialect().(migrate.PostgresDialect); !ok {
+				t.Skip("test database has no PL/pgSQL")
+			}
+
+			// Arrange
+			var (
+				db   = migrate.SetupTestDatabase(t)
+				repo = newRepo(db)
+				name string
+			)
+
+			// Act
+			err := migrate.NewMigrator(db, dollarQuotedMigration, migrate.WithStatementSplitter(migrate.SplitStatements)).Migrate()
+
+			// Assert
+			assert.NoError(t, err)
+			assert.True(t, repo.GetMigrationByName("001_function.sql").IsApplied)
+			err = db.QueryRow("SELECT name FROM test WHERE id = 1").Scan(&name)
+			assert.NoError(t, err)
+			assert.Equal(t, "a;b", name)
+		})
+
+		t.Run("should error with the failing statement with a statement splitter", func(t *testing.T) {
+			// Arrange
+			var (
+				db         = migrate.SetupTestDatabase(t)
+				migrations = fstest.MapFS{
+					"001_test.sql": {Data: []byte("CREATE TABLE test (id INT PRIMARY KEY);\nTHIS IS NOT SQL;\n")},
+				}
+			)
+
+			// Act
+			err := migrate.NewMigrator(db, migrations, migrate.WithStatementSplitter(migrate.SplitStatements)).Migrate()
+
+			// Assert
+			assert.ErrorIs(t, err, migrate.ErrMigrationFailed)
+			assert.ErrorContains(t, err, "statement 2 of 2")
+		})
+
+		t.Run("only applies migrations matching the filter", func(t *testing.T) {
+			// Arrange
+			var (
+				db         = migrate.SetupTestDatabase(t)
+				repo       = newRepo(db)
+				migrations = fstest.MapFS{
+					"billing/001_invoices.sql": {Data: []byte("CREATE TABLE invoices (id INT PRIMARY KEY);")},
+					"users/001_users.sql":      {Data: []byte("CREATE TABLE users (id INT PRIMARY KEY);")},
+				}
+			)
+
+			// Act
+			result, err := migrate.NewMigrator(db, migrations, migrate.WithFilter("billing/*.sql")).MigrateResult()
+
+			// Assert
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"001_invoices.sql"}, result.Applied)
+			assert.Empty(t, repo.GetMigrationByName("001_users.sql").MigrationName)
+			_, err = db.Exec("SELECT id FROM users")
+			assert.Error(t, err)
+		})
+
+		t.Run("does not report the migrations of other filters as missing", func(t *testing.T) {
+			// Arrange
+			var (
+				db         = migrate.SetupTestDatabase(t)
+				repo       = newRepo(db)
+				migrations = fstest.MapFS{
+					"billing/001_invoices.sql": {Data: []byte("CREATE TABLE invoices (id INT PRIMARY KEY);")},
+					"users/002_users.sql":      {Data: []byte("CREATE TABLE users (id INT PRIMARY KEY);")},
+				}
+			)
+			err := migrate.NewMigrator(db, migrations, migrate.WithFilter("users/*.sql")).Migrate()
+			assert.NoError(t, err)
+
+			// Act
+			err = migrate.NewMigrator(db, migrations, migrate.WithFilter("billing/*.sql"), migrate.WithOnMissing(migrate.MissingFail)).Migrate()
+
+			// Assert
+			assert.NoError(t, err)
+			assert.True(t, repo.GetMigrationByName("001_invoices.sql").IsApplied)
+			assert.True(t, repo.GetMigrationByName("002_users.sql").IsApplied)
+		})
+
+		t.Run("reports missing migrations of the filter", func(t *testing.T) {
+			// Arrange
+			var (
+				db         = migrate.SetupTestDatabase(t)
+				migrations = fstest.MapFS{
+					"billing/001_invoices.sql": {Data: []byte("CREATE TABLE invoices (id INT PRIMARY KEY);")},
+					"users/002_users.sql":      {Data: []byte("CREATE TABLE users (id INT PRIMARY KEY);")},
+				}
+			)
+			err := migrate.NewMigrator(db, migrations, migrate.WithFilter("billing/*.sql")).Migrate()
+			assert.NoError(t, err)
+			delete(migrations, "billing/001_invoices.sql")
+
+			// Act
+			err = migrate.NewMigrator(db, migrations, migrate.WithFilter("billing/*.sql"), migrate.WithOnMissing(migrate.MissingFail)).Migrate()
+
+			// Assert
+			assert.ErrorIs(t, err, migrate.ErrMigrationFileMissing)
+			assert.ErrorContains(t, err, `migration "001_invoices.sql"`)
+		})
+
+		t.Run("should error when the filter is malformed", func(t *testing.T) {
+			// Arrange
+			var (
+				db = migrate.SetupTestDatabase(t)
+			)
+
+			// Act
+			err := migrate.NewMigrator(db, noErrorsMigration, migrate.WithFilter("[")).Migrate()
+
+			// Assert
+			assert.ErrorIs(t, err, path.ErrBadPattern)
+		})
+
+		t.Run("warns when there are no migrations", func(t *testing.T) {
+			// Arrange
+			var (
+				db     = migrate.SetupTestDatabase(t)
+				output bytes.Buffer
+				logger = slog.New(slog.NewTextHandler(&output, nil))
+			)
+
+			// Act
+			err := migrate.NewMigrator(db, fstest.MapFS{}, migrate.WithLogger(logger)).Migrate()
+
+			// Assert
+			assert.NoError(t, err)
+			assert.Contains(t, output.String(), `msg="no migrations found"`)
+		})
+
+		t.Run("should error when there are no migrations and erroring on empty", func(t *testing.T) {
+			// Arrange
+			var (
+				db         = migrate.SetupTestDatabase(t)
+				migrations = fstest.MapFS{
+					"README.md": {Data: []byte("# Migrations")},
+				}
+			)
+
+			// Act
+			err := migrate.NewMigrator(db, migrations, migrate.WithErrorOnEmpty()).Migrate()
+
+			// Assert
+			assert.ErrorIs(t, err, migrate.ErrNoMigrations)
+		})
+
+		t.Run("should error before applying anything when there are more migrations than the maximum", func(t *testing.T) {
+			// Arrange
+			var (
+				db   = migrate.SetupTestDatabase(t)
+				repo = newRepo(db)
+			)
+
+			// Act
+			err := migrate.NewMigrator(db, noErrorsMigration, migrate.WithMaxMigrations(1)).Migrate()
+
+			// Assert
+			assert.ErrorIs(t, err, migrate.ErrTooManyMigrations)
+			assert.ErrorContains(t, err, "found 2 migrations, more than the maximum of 1")
+			migrations, err := repo.GetAllMigrations()
+			assert.NoError(t, err)
+			assert.Empty(t, migrations)
+		})
+
+		t.Run("applies migrations up to the maximum", func(t *testing.T) {
+			// Arrange
+			var (
+				db   = migrate.SetupTestDatabase(t)
+				repo = newRepo(db)
+			)
+
+			// Act
+			err := migrate.NewMigrator(db, noErrorsMigration, migrate.WithMaxMigrations(2)).Migrate()
+
+			// Assert
+			assert.NoError(t, err)
+			assert.True(t, repo.GetMigrationByName("002_more_test.sql").IsApplied)
+		})
+
+		t.Run("warns about applied migrations without a file by default", func(t *testing.T) {
+			// Arrange
+			var (
+				db     = migrate.SetupTestDatabase(t)
+				output bytes.Buffer
+				logger = slog.New(slog.NewTextHandler(&output, nil))
+			)
+			err := sut(db, noErrorsMigration)
+			assert.NoError(t, err)
+
+			// Act
+			err = migrate.NewMigrator(db, fstest.MapFS{}, migrate.WithLogger(logger)).Migrate()
+
+			// Assert
+			assert.NoError(t, err)
+			assert.Contains(t, output.String(), `msg="applied migration has no file" migration=001_test.sql`)
+		})
+
+		t.Run("should error on applied migrations without a file when failing on missing", func(t *testing.T) {
+			// Arrange
+			var (
+				db = migrate.SetupTestDatabase(t)
+			)
+			err := sut(db, noErrorsMigration)
+			assert.NoError(t, err)
+
+			// Act
+			err = migrate.NewMigrator(db, fstest.MapFS{}, migrate.WithOnMissing(migrate.MissingFail)).Migrate()
+
+			// Assert
+			assert.ErrorIs(t, err, migrate.ErrMigrationFileMissing)
+			assert.ErrorContains(t, err, `migration "001_test.sql"`)
+			assert.ErrorContains(t, err, `migration "002_more_test.sql"`)
+		})
+
+		t.Run("applies out of order migrations by default", func(t *testing.T) {
+			// Arrange
+			var (
+				db       = migrate.SetupTestDatabase(t)
+				repo     = newRepo(db)
+				deployed = fstest.MapFS{
+					"005_x.sql": {Data: []byte("CREATE TABLE x (id INT PRIMARY KEY);")},
+				}
+				merged = fstest.MapFS{
+					"004_y.sql": {Data: []byte("CREATE TABLE y (id INT PRIMARY KEY);")},
+					"005_x.sql": deployed["005_x.sql"],
+				}
+			)
+			err := sut(db, deployed)
+			assert.NoError(t, err)
+
+			// Act
+			err = sut(db, merged)
+
+			// Assert
+			assert.NoError(t, err)
+			assert.True(t, repo.GetMigrationByName("004_y.sql").IsApplied)
+		})
+
+		t.Run("should error on out of order migrations when strict", func(t *testing.T) {
+			// Arrange
+			var (
+				db       = migrate.SetupTestDatabase(t)
+				repo     = newRepo(db)
+				deployed = fstest.MapFS{
+					"005_x.sql": {Data: []byte("CREATE TABLE x (id INT PRIMARY KEY);")},
+				}
+				merged = fstest.MapFS{
+					"004_y.sql": {Data: []byte("CREATE TABLE y (id INT PRIMARY KEY);")},
+					"005_x.sql": deployed["005_x.sql"],
+				}
+			)
+			err := sut(db, deployed)
+			assert.NoError(t, err)
+
+			// Act
+			err = migrate.NewMigrator(db, merged, migrate.WithOutOfOrder(migrate.OutOfOrderStrict)).Migrate()
+
+			// Assert
+			assert.ErrorIs(t, err, migrate.ErrOutOfOrderMigration)
+			assert.ErrorContains(t, err, `migration "004_y.sql" has version 4, but version 5 is already applied`)
+			assert.Empty(t, repo.GetMigrationByName("004_y.sql").MigrationName)
+		})
+
+		t.Run("ignores altered migrations when skipping the hash check", func(t *testing.T) {
+			// Arrange
+			var (
+				db   = migrate.SetupTestDatabase(t)
+				repo = newRepo(db)
+			)
+			err := sut(db, changingMigrations)
+			assert.NoError(t, err)
+			before := repo.GetMigrationByName("001_test.sql")
+
+			// Act
+			err = migrate.NewMigrator(db, changingMigrationsChanged, migrate.WithSkipHashCheck()).Migrate()
+
+			// Assert
+			assert.NoError(t, err)
+			assert.Equal(t, before.MigrationHash, repo.GetMigrationByName("001_test.sql").MigrationHash)
+		})
+
+		t.Run("warns and keeps the stored hash when altered migrations are allowed", func(t *testing.T) {
+			// Arrange
+			var (
+				db     = migrate.SetupTestDatabase(t)
+				repo   = newRepo(db)
+				output bytes.Buffer
+				logger = slog.New(slog.NewTextHandler(&output, nil))
+			)
+			err := sut(db, changingMigrations)
+			assert.NoError(t, err)
+			before := repo.GetMigrationByName("001_test.sql")
+
+			// Act
+			err = migrate.NewMigrator(db, changingMigrationsChanged, migrate.WithOnAltered(migrate.AlteredWarn), migrate.WithLogger(logger)).Migrate()
+
+			// Assert
+			assert.NoError(t, err)
+			assert.Contains(t, output.String(), `level=WARN msg="migration has been altered" migration=001_test.sql`)
+			assert.Equal(t, before.MigrationHash, repo.GetMigrationByName("001_test.sql").MigrationHash)
+		})
+
+		t.Run("updates the stored hash of altered migrations", func(t *testing.T) {
+			// Arrange
+			var (
+				db = migrate.SetupTestDatabase(t)
+			)
+			err := sut(db, changingMigrations)
+			assert.NoError(t, err)
+
+			// Act
+			err = migrate.NewMigrator(db, changingMigrationsChanged, migrate.WithOnAltered(migrate.AlteredUpdate)).Migrate()
+
+			// Assert
+			assert.NoError(t, err)
+			err = sut(db, changingMigrationsChanged)
+			assert.NoError(t, err)
+		})
+
+		t.Run("stores hashes of the configured hasher", func(t *testing.T) {
+			// Arrange
+			var (
+				db     = migrate.SetupTestDatabase(t)
+				repo   = newRepo(db)
+				hasher = func(content []byte) string {
+					return fmt.Sprintf("len-%d", len(content))
+				}
+				sut = func() error {
+					return migrate.NewMigrator(db, noErrorsMigration, migrate.WithHasher(hasher)).Migrate()
+				}
+			)
+			content, err := fs.ReadFile(noErrorsMigration, "test_data/two_files_no_error/001_test.sql")
+			assert.NoError(t, err)
+
+			// Act
+			err = sut()
+			assert.NoError(t, err)
+			err = sut()
+
+			// Assert
+			assert.NoError(t, err)
+			assert.Equal(t, hasher(content), repo.GetMigrationByName("001_test.sql").MigrationHash)
+		})
+
+		t.Run("ignores line endings when normalizing them", func(t *testing.T) {
+			// Arrange
+			var (
+				db   = migrate.SetupTestDatabase(t)
+				crlf = fstest.MapFS{
+					"001_test.sql": {Data: []byte("CREATE TABLE test (\r\n    id INT PRIMARY KEY\r\n);\r\n")},
+				}
+				lf = fstest.MapFS{
+					"001_test.sql": {Data: []byte("CREATE TABLE test (\n    id INT PRIMARY KEY\n);\n")},
+				}
+			)
+			err := migrate.NewMigrator(db, crlf, migrate.WithNormalizeLineEndings(true)).Migrate()
+			assert.NoError(t, err)
+
+			// Act
+			err = migrate.NewMigrator(db, lf, migrate.WithNormalizeLineEndings(true)).Migrate()
+
+			// Assert
+			assert.NoError(t, err)
+		})
+
+		t.Run("ignores a byte order mark and trailing whitespace when normalizing content", func(t *testing.T) {
+			// Arrange
+			var db = migrate.SetupTestDatabase(t)
+			err := migrate.NewMigrator(db, withoutBOMMigration, migrate.WithNormalizeContent(true)).Migrate()
+			assert.NoError(t, err)
+
+			// Act
+			err = migrate.NewMigrator(db, withBOMMigration, migrate.WithNormalizeContent(true)).Migrate()
+
+			// Assert
+			assert.NoError(t, err)
+		})
+
+		t.Run("should error when only a byte order mark was added without normalizing content", func(t *testing.T) {
+			// Arrange
+			var db = migrate.SetupTestDatabase(t)
+			err := migrate.NewMigrator(db, withoutBOMMigration).Migrate()
+			assert.NoError(t, err)
+
+			// Act
+			err = migrate.NewMigrator(db, withBOMMigration).Migrate()
+
+			// Assert
+			assert.ErrorIs(t, err, migrate.ErrMigrationFileChanged)
+		})
+
+		t.Run("should error when only line endings changed without normalizing them", func(t *testing.T) {
+			// Arrange
+			var (
+				db   = migrate.SetupTestDatabase(t)
+				crlf = fstest.MapFS{
+					"001_test.sql": {Data: []byte("CREATE TABLE test (\r\n    id INT PRIMARY KEY\r\n);\r\n")},
+				}
+				lf = fstest.MapFS{
+					"001_test.sql": {Data: []byte("CREATE TABLE test (\n    id INT PRIMARY KEY\n);\n")},
+				}
+			)
+			err := migrate.NewMigrator(db, crlf).Migrate()
+			assert.NoError(t, err)
+
+			// Act
+			err = migrate.NewMigrator(db, lf).Migrate()
+
+			// Assert
+			assert.ErrorIs(t, err, migrate.ErrMigrationFileChanged)
+		})
+
+		t.Run("stores hashes longer than a SHA-256 digest", func(t *testing.T) {
+			// Arrange
+			var (
+				db     = migrate.SetupTestDatabase(t)
+				repo   = newRepo(db)
+				hasher = func(content []byte) string {
+					sum := sha512.Sum512(content)
+					return hex.EncodeToString(sum[:])
+				}
+			)
+			content, err := fs.ReadFile(noErrorsMigration, "test_data/two_files_no_error/001_test.sql")
+			assert.NoError(t, err)
+
+			// Act
+			err = migrate.NewMigrator(db, noErrorsMigration, migrate.WithHasher(hasher)).Migrate()
+
+			// Assert
+			assert.NoError(t, err)
+			assert.Equal(t, hasher(content), repo.GetMigrationByName("001_test.sql").MigrationHash)
+			assert.Len(t, repo.GetMigrationByName("001_test.sql").MigrationHash, 128)
+		})
+
+		t.Run("accepts and upgrades hashes stored by the legacy scheme", func(t *testing.T) {
+			// Arrange
+			var (
+				db   = migrate.SetupTestDatabase(t)
+				repo = newRepo(db)
+			)
+			err := sut(db, noErrorsMigration)
+			assert.NoError(t, err)
+			content, err := fs.ReadFile(noErrorsMigration, "test_data/two_files_no_error/001_test.sql")
+			assert.NoError(t, err)
+			legacyHash := fmt.Sprintf("%x", sha256.Sum256(fmt.Appendf(nil, "%v", content)))
+			_, err = db.Exec("UPDATE migrations SET migration_hash = $1 WHERE migration_name = $2", legacyHash, "001_test.sql")
+			assert.NoError(t, err)
+
+			// Act
+			err = sut(db, noErrorsMigration)
+
+			// Assert
+			assert.NoError(t, err)
+			assert.Equal(t, migrate.SHA256Hasher(content), repo.GetMigrationByName("001_test.sql").MigrationHash)
+		})
+
+		t.Run("reports the failing migration as a MigrationError", func(t *testing.T) {
+			// Arrange
+			var (
+				db             = migrate.SetupTestDatabase(t)
+				migrationError *migrate.MigrationError
+			)
+
+			// Act
+			err := sut(db, partiallyInvalidMigration)
+
+			// Assert
+			assert.ErrorIs(t, err, migrate.ErrMigrationFailed)
+			assert.ErrorAs(t, err, &migrationError)
+			assert.Equal(t, "002_invalid.sql", migrationError.Migration)
+			assert.Equal(t, migrate.PhaseExecute, migrationError.Phase)
+			assert.Error(t, migrationError.Err)
+			assert.NotErrorIs(t, migrationError.Err, migrate.ErrMigrationFailed)
+		})
+
+		t.Run("reports an altered migration as a MigrationError", func(t *testing.T) {
+			// Arrange
+			var (
+				db             = migrate.SetupTestDatabase(t)
+				migrationError *migrate.MigrationError
+			)
+			err := sut(db, changingMigrations)
+			assert.NoError(t, err)
+
+			// Act
+			err = sut(db, changingMigrationsChanged)
+
+			// Assert
+			assert.ErrorAs(t, err, &migrationError)
+			assert.Equal(t, "001_test.sql", migrationError.Migration)
+			assert.Equal(t, migrate.PhaseCheck, migrationError.Phase)
+			assert.ErrorIs(t, migrationError, migrate.ErrMigrationFileChanged)
+		})
+
+		t.Run("should error when migration has invalid sql", func(t *testing.T) {
+			// Arrange
+			var (
+				db   = migrate.SetupTestDatabase(t)
+				repo = newRepo(db)
+			)
+
+			// Act
+			err := sut(db, invalidMigration)
+
+			// Assert
+			assert.Error(t, err)
+			assert.ErrorIs(t, err, migrate.ErrMigrationFailed)
+			migrations, err := repo.GetAllMigrations()
+			assert.NoError(t, err)
+			assert.Len(t, migrations, 1)
+			assert.False(t, migrations[0].IsApplied)
+			assert.True(t, migrations[0].IsDirty)
+		})
+
+		t.Run("migrate with context", func(t *testing.T) {
+			// Arrange
+			var (
+				db   = migrate.SetupTestDatabase(t)
+				repo = newRepo(db)
+			)
+
+			// Act
+			err := migrate.NewMigrator(db, noErrorsMigration).MigrateContext(context.Background())
+
+			// Assert
+			assert.NoError(t, err)
+			migrations, err := repo.GetAllMigrations()
+			assert.NoError(t, err)
+			assert.Len(t, migrations, 2)
+		})
+
+		t.Run("should error when context is cancelled", func(t *testing.T) {
+			// Arrange
+			var (
+				db          = migrate.SetupTestDatabase(t)
+				ctx, cancel = context.WithCancel(context.Background())
+			)
+			cancel()
+
+			// Act
+			err := migrate.NewMigrator(db, noErrorsMigration).MigrateContext(ctx)
+
+			// Assert
+			assert.ErrorIs(t, err, context.Canceled)
+		})
+
+		t.Run("successfully migrate with explicit dialect", func(t *testing.T) {
+			// Arrange
+			var (
+				db      = migrate.SetupTestDatabase(t)
+				repo    = newRepo(db)
+				dialect = &spyDialect{Dialect: testDialect()}
+			)
+
+			// Act
+			err := migrate.NewMigrator(db, noErrorsMigration, migrate.WithDialect(dialect)).Migrate()
+
+			// Assert
+			assert.NoError(t, err)
+			assert.Equal(t, 4, dialect.upserts)
+			migrations, err := repo.GetAllMigrations()
+			assert.NoError(t, err)
+			assert.Len(t, migrations, 2)
+		})
+
+		t.Run("tracks migrations in a custom table", func(t *testing.T) {
+			// Arrange
+			var (
+				db         = migrate.SetupTestDatabase(t)
+				repo       = newRepo(db)
+				customRepo = test_data.NewRepoForTable(db, "schema_history")
+			)
+
+			// Act
+			err := migrate.NewMigrator(db, noErrorsMigration, migrate.WithTableName("schema_history")).Migrate()
+
+			// Assert
+			assert.NoError(t, err)
+			migrations, err := customRepo.GetAllMigrations()
+			assert.NoError(t, err)
+			assert.Len(t, migrations, 2)
+			_, err = repo.GetAllMigrations()
+			assert.Error(t, err, "default migrations table should not exist")
+		})
+
+		t.Run("should error when table name is not an identifier", func(t *testing.T) {
+			// Arrange
+			var (
+				db = migrate.SetupTestDatabase(t)
+			)
+
+			// Act
+			err := migrate.NewMigrator(db, noErrorsMigration, migrate.WithTableName("migrations; DROP TABLE users")).Migrate()
+
+			// Assert
+			assert.ErrorContains(t, err, "invalid table name")
+		})
+
+		t.Run("stores the description directive", func(t *testing.T) {
+			// Arrange
+			var (
+				db         = migrate.SetupTestDatabase(t)
+				migrations = fstest.MapFS{
+					"001_test.sql": {Data: []byte("-- migrate:description Add the test table\nCREATE TABLE test (id INT PRIMARY KEY);")},
+					"002_more.sql": {Data: []byte("CREATE TABLE more_test (id INT PRIMARY KEY);")},
+				}
+				description string
+			)
+
+			// Act
+			err := sut(db, migrations)
+
+			// Assert
+			assert.NoError(t, err)
+			err = db.QueryRow("SELECT description FROM migrations WHERE migration_name = $1", "001_test.sql").Scan(&description)
+			assert.NoError(t, err)
+			assert.Equal(t, "Add the test table", description)
+			statuses, err := migrate.NewMigrator(db, migrations).Status()
+			assert.NoError(t, err)
+			assert.Equal(t, "Add the test table", statuses[0].Description)
+			assert.Empty(t, statuses[1].Description)
+		})
+
+		t.Run("adds the description column to older migrations tables", func(t *testing.T) {
+			// Arrange
+			var (
+				db   = migrate.SetupTestDatabase(t)
+				repo = newRepo(db)
+			)
+			_, err := db.Exec("CREATE TABLE migrations (migration_name VARCHAR(255) PRIMARY KEY, migration_hash VARCHAR(64), is_applied BOOLEAN NOT NULL DEFAULT FALSE, is_dirty BOOLEAN NOT NULL DEFAULT FALSE, applied_at TIMESTAMP)")
+			assert.NoError(t, err)
+
+			// Act
+			err = sut(db, noErrorsMigration)
+
+			// Assert
+			assert.NoError(t, err)
+			assert.True(t, repo.GetMigrationByName("001_test.sql").IsApplied)
+			_, err = db.Exec("SELECT description FROM migrations")
+			assert.NoError(t, err)
+		})
+
+		t.Run("should error when migrations table has unexpected columns", func(t *testing.T) {
+			// Arrange
+			var (
+				db = migrate.SetupTestDatabase(t)
+			)
+			_, err := db.Exec("CREATE TABLE migrations (migration_name VARCHAR(255) PRIMARY KEY, migration_hash VARCHAR(64), is_applied BOOLEAN)")
+			assert.NoError(t, err)
+
+			// Act
+			err = sut(db, noErrorsMigration)
+
+			// Assert
+			assert.ErrorIs(t, err, migrate.ErrInvalidMigrationsTable)
+			assert.ErrorContains(t, err, "is_dirty")
+		})
+
+		t.Run("should error when migrations table has columns of unexpected types", func(t *testing.T) {
+			// Arrange
+			var (
+				db = migrate.SetupTestDatabase(t)
+			)
+			_, err := db.Exec("CREATE TABLE migrations (migration_name VARCHAR(255) PRIMARY KEY, migration_hash VARCHAR(64), is_applied TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP, is_dirty BOOLEAN NOT NULL DEFAULT FALSE, applied_at VARCHAR(32))")
+			assert.NoError(t, err)
+
+			// Act
+			err = sut(db, noErrorsMigration)
+
+			// Assert
+			assert.ErrorIs(t, err, migrate.ErrInvalidMigrationsTable)
+			assert.ErrorContains(t, err, "is_applied is TIMESTAMP instead of a boolean type")
+			assert.ErrorContains(t, err, "applied_at is VARCHAR")
+		})
+
+		t.Run("should error without altering a table of another tool", func(t *testing.T) {
+			// Arrange
+			var (
+				db = migrate.SetupTestDatabase(t)
+			)
+			_, err := db.Exec("CREATE TABLE schema_migrations (version BIGINT PRIMARY KEY, dirty BOOLEAN NOT NULL)")
+			assert.NoError(t, err)
+
+			// Act
+			err = migrate.NewMigrator(db, noErrorsMigration, migrate.WithTableName("schema_migrations")).Migrate()
+
+			// Assert
+			assert.ErrorIs(t, err, migrate.ErrInvalidMigrationsTable)
+			assert.ErrorContains(t, err, "it has version, dirty")
+			assert.ErrorContains(t, err, "WithTableName")
+			_, err = db.Exec("SELECT applied_at FROM schema_migrations")
+			assert.Error(t, err)
+		})
+
+		t.Run("creates the migrations table with the configured DDL", func(t *testing.T) {
+			// Arrange
+			var (
+				db   = migrate.SetupTestDatabase(t)
+				repo = newRepo(db)
+				ddl  = "CREATE TABLE IF NOT EXISTS migrations (migration_name VARCHAR(255) PRIMARY KEY, migration_hash TEXT, is_applied BOOLEAN NOT NULL DEFAULT FALSE, is_dirty BOOLEAN NOT NULL DEFAULT FALSE, applied_at TIMESTAMP, checksum_algo VARCHAR(16) DEFAULT 'sha256')"
+			)
+
+			// Act
+			err := migrate.NewMigrator(db, noErrorsMigration, migrate.WithTableDDL(ddl)).Migrate()
+
+			// Assert
+			assert.NoError(t, err)
+			assert.True(t, repo.GetMigrationByName("001_test.sql").IsApplied)
+			var algo string
+			assert.NoError(t, db.QueryRow("SELECT checksum_algo FROM migrations WHERE migration_name = '001_test.sql'").Scan(&algo))
+			assert.Equal(t, "sha256", algo)
+		})
+
+		t.Run("should error when the configured DDL lacks a column", func(t *testing.T) {
+			// Arrange
+			var (
+				db  = migrate.SetupTestDatabase(t)
+				ddl = "CREATE TABLE IF NOT EXISTS migrations (migration_name VARCHAR(255) PRIMARY KEY, migration_hash TEXT, is_applied BOOLEAN)"
+			)
+
+			// Act
+			err := migrate.NewMigrator(db, noErrorsMigration, migrate.WithTableDDL(ddl)).Migrate()
+
+			// Assert
+			assert.ErrorIs(t, err, migrate.ErrInvalidMigrationsTable)
+			assert.ErrorContains(t, err, "is_dirty, applied_at")
+		})
+
+		t.Run("migrates into the configured schema", func(t *testing.T) {
+			if _, ok := testDialect().(migrate.SchemaSelector); !ok {
+				t.Skip("test database has no schemas")
+			}
+
+			// Arrange
+			var (
+				db     = migrate.SetupTestDatabase(t)
+				schema = fmt.Sprintf("app_%d", time.Now().UnixNano())
+				count  int
+			)
+			t.Cleanup(func() {
+				_, _ = db.Exec(fmt.Sprintf("DROP SCHEMA %s CASCADE", schema))
+			})
+
+			// Act
+			err := migrate.NewMigrator(db, noErrorsMigration, migrate.WithSchema(schema)).Migrate()
+
+			// Assert
+			assert.NoError(t, err)
+			err = db.QueryRow(fmt.Sprintf("SELECT COUNT(*) FROM %s.migrations", schema)).Scan(&count)
+			assert.NoError(t, err)
+			assert.Equal(t, 2, count)
+		})
+
+		t.Run("keeps the migrations table in the configured table schema", func(t *testing.T) {
+			if _, ok := testDialect().(migrate.SchemaCreator); !ok {
+				t.Skip("test database has no schemas")
+			}
+
+			// Arrange
+			var (
+				db          = migrate.SetupTestDatabase(t)
+				tableSchema = fmt.Sprintf("migrations_%d", time.Now().UnixNano())
+				count       int
+			)
+			t.Cleanup(func() {
+				_, _ = db.Exec(fmt.Sprintf("DROP SCHEMA %s CASCADE", tableSchema))
+			})
+
+			// Act
+			err := migrate.NewMigrator(db, noErrorsMigration, migrate.WithTableSchema(tableSchema)).Migrate()
+
+			// Assert
+			assert.NoError(t, err)
+			err = db.QueryRow(fmt.Sprintf("SELECT COUNT(*) FROM %s.migrations", tableSchema)).Scan(&count)
+			assert.NoError(t, err)
+			assert.Equal(t, 2, count)
+			_, err = db.Exec("SELECT id FROM test")
+			assert.NoError(t, err)
+			_, err = db.Exec("SELECT migration_name FROM migrations")
+			assert.Error(t, err)
+		})
+
+		t.Run("should error when schema is not a plain identifier", func(t *testing.T) {
+			// Arrange
+			var (
+				db = migrate.SetupTestDatabase(t)
+			)
+
+			// Act
+			err := migrate.NewMigrator(db, noErrorsMigration, migrate.WithSchema("app; DROP TABLE users")).Migrate()
+
+			// Assert
+			assert.ErrorContains(t, err, "invalid schema")
+		})
+
+		t.Run("keeps migrations that succeeded before a failure", func(t *testing.T) {
+			// Arrange
+			var (
+				db   = migrate.SetupTestDatabase(t)
+				repo = newRepo(db)
+			)
+
+			// Act
+			err := sut(db, partiallyInvalidMigration)
+
+			// Assert
+			assert.ErrorIs(t, err, migrate.ErrMigrationFailed)
+			succeeded := repo.GetMigrationByName("001_test.sql")
+			assert.True(t, succeeded.IsApplied)
+			assert.False(t, succeeded.IsDirty)
+			failed := repo.GetMigrationByName("002_invalid.sql")
+			assert.False(t, failed.IsApplied)
+			assert.True(t, failed.IsDirty)
+			assert.True(t, failed.AppliedAt.IsZero())
+		})
+
+		t.Run("rolls back only the failing migration per migration", func(t *testing.T) {
+			// Arrange
+			var (
+				db   = migrate.SetupTestDatabase(t)
+				repo = newRepo(db)
+			)
+
+			// Act
+			err := migrate.NewMigrator(db, partiallyInvalidMigration, migrate.WithTransactionMode(migrate.PerMigration)).Migrate()
+
+			// Assert
+			assert.ErrorIs(t, err, migrate.ErrMigrationFailed)
+			succeeded := repo.GetMigrationByName("001_test.sql")
+			assert.True(t, succeeded.IsApplied)
+			assert.False(t, succeeded.IsDirty)
+			failed := repo.GetMigrationByName("002_invalid.sql")
+			assert.Empty(t, failed.MigrationName)
+		})
+
+		t.Run("runs migrations outside transactions when the database cannot roll back DDL", func(t *testing.T) {
+			// Arrange
+			var (
+				db      = migrate.SetupTestDatabase(t)
+				repo    = newRepo(db)
+				output  bytes.Buffer
+				logger  = slog.New(slog.NewTextHandler(&output, nil))
+				dialect = nonTransactionalDDLDialect{Dialect: testDialect()}
+			)
+
+			// Act
+			err := migrate.NewMigrator(db, partiallyInvalidMigration, migrate.WithDialect(dialect), migrate.WithTransactionMode(migrate.PerMigration), migrate.WithLogger(logger)).Migrate()
+
+			// Assert
+			assert.ErrorIs(t, err, migrate.ErrMigrationFailed)
+			assert.True(t, repo.GetMigrationByName("001_test.sql").IsApplied)
+			assert.True(t, repo.GetMigrationByName("002_invalid.sql").IsDirty)
+			assert.Contains(t, output.String(), "cannot roll back DDL")
+		})
+
+		t.Run("rolls back every migration of the run all in one", func(t *testing.T) {
+			// Arrange
+			var (
+				db   = migrate.SetupTestDatabase(t)
+				repo = newRepo(db)
+			)
+
+			// Act
+			err := migrate.NewMigrator(db, partiallyInvalidMigration, migrate.WithTransactionMode(migrate.AllInOne)).Migrate()
+
+			// Assert
+			assert.ErrorIs(t, err, migrate.ErrMigrationFailed)
+			migrations, err := repo.GetAllMigrations()
+			assert.NoError(t, err)
+			assert.Empty(t, migrations)
+			_, err = db.Exec("SELECT id FROM test")
+			assert.Error(t, err)
+		})
+
+		t.Run("keeps the migrations before a failing one all in one with keep succeeded", func(t *testing.T) {
+			// Arrange
+			var (
+				db   = migrate.SetupTestDatabase(t)
+				repo = newRepo(db)
+			)
+
+			// Act
+			result, err := migrate.NewMigrator(db, partiallyInvalidMigration, migrate.WithTransactionMode(migrate.AllInOne), migrate.WithKeepSucceeded(true)).MigrateResult()
+
+			// Assert
+			assert.ErrorIs(t, err, migrate.ErrMigrationFailed)
+			assert.Equal(t, 1, result.Count)
+			assert.Equal(t, []string{"001_test.sql"}, result.Applied)
+			assert.True(t, repo.GetMigrationByName("001_test.sql").IsApplied)
+			assert.False(t, repo.GetMigrationByName("002_invalid.sql").IsDirty)
+			_, err = db.Exec("SELECT id FROM test")
+			assert.NoError(t, err)
+		})
+
+		t.Run("rolls back every migration of the run all in one without keep succeeded", func(t *testing.T) {
+			// Arrange
+			var (
+				db   = migrate.SetupTestDatabase(t)
+				repo = newRepo(db)
+			)
+
+			// Act
+			result, err := migrate.NewMigrator(db, partiallyInvalidMigration, migrate.WithTransactionMode(migrate.AllInOne), migrate.WithKeepSucceeded(false)).MigrateResult()
+
+			// Assert
+			assert.ErrorIs(t, err, migrate.ErrMigrationFailed)
+			assert.Zero(t, result.Count)
+			migrations, err := repo.GetAllMigrations()
+			assert.NoError(t, err)
+			assert.Empty(t, migrations)
+		})
+
+		t.Run("commits the successful migrations all in one with savepoints", func(t *testing.T) {
+			// Arrange
+			var (
+				db         = migrate.SetupTestDatabase(t)
+				repo       = newRepo(db)
+				migrations = fstest.MapFS{
+					"001_test.sql":    {Data: []byte("CREATE TABLE test (id INT PRIMARY KEY);")},
+					"002_invalid.sql": {Data: []byte("CREATE TABLE invalid (id INT PRIMARY KEY")},
+					"003_more.sql":    {Data: []byte("CREATE TABLE more_test (id INT PRIMARY KEY);")},
+				}
+			)
+
+			// Act
+			err := migrate.NewMigrator(db, migrations, migrate.WithTransactionMode(migrate.AllInOne), migrate.WithSavepoints()).Migrate()
+
+			// Assert
+			assert.ErrorIs(t, err, migrate.ErrMigrationFailed)
+			assert.ErrorContains(t, err, "002_invalid.sql")
+			migrationRows, err := repo.GetAllMigrations()
+			assert.NoError(t, err)
+			assert.Len(t, migrationRows, 2)
+			assert.True(t, repo.GetMigrationByName("001_test.sql").IsApplied)
+			assert.True(t, repo.GetMigrationByName("003_more.sql").IsApplied)
+			_, err = db.Exec("SELECT id FROM more_test")
+			assert.NoError(t, err)
+		})
+
+		t.Run("applies migrations in transactions of the configured isolation level", func(t *testing.T) {
+			// Arrange
+			var (
+				db   = migrate.SetupTestDatabase(t)
+				repo = newRepo(db)
+			)
+
+			// Act
+			err := migrate.NewMigrator(db, noErrorsMigration, migrate.WithTransactionMode(migrate.PerMigration), migrate.WithIsolationLevel(sql.LevelSerializable)).Migrate()
+
+			// Assert
+			assert.NoError(t, err)
+			assert.True(t, repo.GetMigrationByName("002_more_test.sql").IsApplied)
+		})
+
+		t.Run("begins the transaction with the configured isolation level", func(t *testing.T) {
+			if _, ok := testDialect().(migrate.PostgresDialect); !ok {
+				t.Skip("test database has no transaction_isolation setting")
+			}
+
+			// Arrange
+			var (
+				db         = migrate.SetupTestDatabase(t)
+				migrations = fstest.MapFS{
+					"001_test.sql": {Data: []byte("DO $$ BEGIN IF current_setting('transaction_isolation') <> 'serializable' THEN RAISE EXCEPTION 'not serializable'; END IF; END $$;")},
+				}
+			)
+
+			// Act
+			err := migrate.NewMigrator(db, migrations, migrate.WithTransactionMode(migrate.AllInOne), migrate.WithIsolationLevel(sql.LevelSerializable)).Migrate()
+
+			// Assert
+			assert.NoError(t, err)
+		})
+
+		t.Run("rolls back the run all in one when the post-migration check fails", func(t *testing.T) {
+			// Arrange
+			var (
+				db    = migrate.SetupTestDatabase(t)
+				repo  = newRepo(db)
+				check = func(ctx context.Context, tx *sql.Tx) error {
+					var count int
+					err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM test").Scan(&count)
+					if err != nil {
+						return err
+					}
+					if count == 0 {
+						return fmt.Errorf("test table is empty")
+					}
+					return nil
+				}
+			)
+
+			// Act
+			err := migrate.NewMigrator(db, noErrorsMigration, migrate.WithTransactionMode(migrate.AllInOne), migrate.WithPostMigrationCheck(check)).Migrate()
+
+			// Assert
+			assert.ErrorContains(t, err, "post-migration check: test table is empty")
+			migrationRows, err := repo.GetAllMigrations()
+			assert.NoError(t, err)
+			assert.Empty(t, migrationRows)
+		})
+
+		t.Run("reports a failing post-migration check after committing per migration", func(t *testing.T) {
+			// Arrange
+			var (
+				db    = migrate.SetupTestDatabase(t)
+				repo  = newRepo(db)
+				check = func(ctx context.Context, tx *sql.Tx) error {
+					return fmt.Errorf("invariant violated")
+				}
+			)
+
+			// Act
+			err := migrate.NewMigrator(db, noErrorsMigration, migrate.WithTransactionMode(migrate.PerMigration), migrate.WithPostMigrationCheck(check)).Migrate()
+
+			// Assert
+			assert.ErrorContains(t, err, "post-migration check: invariant violated")
+			migrationRows, err := repo.GetAllMigrations()
+			assert.NoError(t, err)
+			assert.Len(t, migrationRows, 2)
+		})
+
+		t.Run("runs no transaction migrations outside the transaction per migration", func(t *testing.T) {
+			// Arrange
+			var (
+				db   = migrate.SetupTestDatabase(t)
+				repo = newRepo(db)
+			)
+
+			// Act
+			err := migrate.NewMigrator(db, noTransactionMigrations, migrate.WithTransactionMode(migrate.PerMigration)).Migrate()
+
+			// Assert
+			assert.ErrorIs(t, err, migrate.ErrMigrationFailed)
+			failed := repo.GetMigrationByName("002_invalid.sql")
+			assert.False(t, failed.IsApplied)
+			assert.True(t, failed.IsDirty)
+		})
+
+		t.Run("refuses no transaction migrations all in one", func(t *testing.T) {
+			// Arrange
+			var (
+				db   = migrate.SetupTestDatabase(t)
+				repo = newRepo(db)
+			)
+
+			// Act
+			err := migrate.NewMigrator(db, noTransactionMigrations, migrate.WithTransactionMode(migrate.AllInOne)).Migrate()
+
+			// Assert
+			assert.ErrorContains(t, err, "no-transaction directive cannot be used with AllInOne")
+			migrations, err := repo.GetAllMigrations()
+			assert.NoError(t, err)
+			assert.Empty(t, migrations)
+		})
+
+		t.Run("applies every migration all in one", func(t *testing.T) {
+			// Arrange
+			var (
+				db   = migrate.SetupTestDatabase(t)
+				repo = newRepo(db)
+			)
+
+			// Act
+			err := migrate.NewMigrator(db, noErrorsMigration, migrate.WithTransactionMode(migrate.AllInOne)).Migrate()
+
+			// Assert
+			assert.NoError(t, err)
+			migrations, err := repo.GetAllMigrations()
+			assert.NoError(t, err)
+			assert.Len(t, migrations, 2)
+		})
+
+		t.Run("successfully migrate directory layout", func(t *testing.T) {
+			// Arrange
+			var (
+				db   = migrate.SetupTestDatabase(t)
+				repo = newRepo(db)
+			)
+
+			// Act
+			err := migrate.NewMigrator(db, directoryLayoutMigrations, migrate.WithLayout(migrate.DirectoryLayout)).Migrate()
+
+			// Assert
+			assert.NoError(t, err)
+			migrations, err := repo.GetAllMigrations()
+			assert.NoError(t, err)
+			assert.Len(t, migrations, 2)
+			assert.True(t, repo.GetMigrationByName("001_create_users").IsApplied)
+			assert.True(t, repo.GetMigrationByName("002_add_user_email").IsApplied)
+		})
+
+		t.Run("loads referenced data files", func(t *testing.T) {
+			// Arrange
+			var (
+				db     = migrate.SetupTestDatabase(t)
+				dataFS = fstest.MapFS{
+					"users.csv": {Data: []byte("id,name\n1,alice\n2,bob\n3,\n")},
+				}
+				count int
+			)
+
+			// Act
+			err := migrate.NewMigrator(db, dataFileMigration, migrate.WithDataFS(dataFS)).Migrate()
+
+			// Assert
+			assert.NoError(t, err)
+			err = db.QueryRow("SELECT count(*) FROM users WHERE name IS NOT NULL").Scan(&count)
+			assert.NoError(t, err)
+			assert.Equal(t, 2, count)
+		})
+
+		t.Run("should error when referenced data file changed", func(t *testing.T) {
+			// Arrange
+			var (
+				db  = migrate.SetupTestDatabase(t)
+				sut = func(data string) error {
+					dataFS := fstest.MapFS{"users.csv": {Data: []byte(data)}}
+					return migrate.NewMigrator(db, dataFileMigration, migrate.WithDataFS(dataFS)).Migrate()
+				}
+			)
+			err := sut("id,name\n1,alice\n")
+			assert.NoError(t, err)
+
+			// Act
+			err = sut("id,name\n1,alice\n2,bob\n")
+
+			// Assert
+			assert.ErrorIs(t, err, migrate.ErrMigrationFileChanged)
+		})
+
+		t.Run("should error when the data file of an applied migration cannot be hashed", func(t *testing.T) {
+			// Arrange
+			var (
+				db     = migrate.SetupTestDatabase(t)
+				dataFS = fstest.MapFS{"users.csv": {Data: []byte("id,name\n1,alice\n")}}
+			)
+			err := migrate.NewMigrator(db, dataFileMigration, migrate.WithDataFS(dataFS)).Migrate()
+			assert.NoError(t, err)
+
+			// Act
+			err = migrate.NewMigrator(db, dataFileMigration, migrate.WithDataFS(fstest.MapFS{})).Migrate()
+
+			// Assert
+			assert.ErrorIs(t, err, fs.ErrNotExist)
+			assert.ErrorContains(t, err, `hash migration "001_create_users.sql"`)
+		})
+
+		t.Run("should error when data filesystem is missing", func(t *testing.T) {
+			// Arrange
+			var (
+				db   = migrate.SetupTestDatabase(t)
+				repo = newRepo(db)
+			)
+
+			// Act
+			err := sut(db, dataFileMigration)
+
+			// Assert
+			assert.Error(t, err)
+			migrations, err := repo.GetAllMigrations()
+			assert.NoError(t, err)
+			assert.Empty(t, migrations)
+		})
+
+		t.Run("applies parallel-safe migrations concurrently", func(t *testing.T) {
+			// Arrange
+			var (
+				db   = migrate.SetupTestDatabase(t)
+				repo = newRepo(db)
+			)
+
+			// Act
+			err := migrate.NewMigrator(db, parallelSafeMigrations, migrate.WithParallelism(3)).Migrate()
+
+			// Assert
+			assert.NoError(t, err)
+			migrations, err := repo.GetAllMigrations()
+			assert.NoError(t, err)
+			assert.Len(t, migrations, 4)
+			for _, migration := range migrations {
+				assert.True(t, migration.IsApplied)
+				assert.False(t, migration.IsDirty)
+			}
+		})
+
+		t.Run("overlaps parallel-safe migrations", func(t *testing.T) {
+			// Arrange
+			var (
+				db         = setupConcurrentTestDatabase(t)
+				repo       = newRepo(db)
+				migrations = fstest.MapFS{
+					"001_first.sql":  {Data: []byte("-- migrate:parallel-safe\nCREATE TABLE first (id INT PRIMARY KEY);")},
+					"002_second.sql": {Data: []byte("-- migrate:parallel-safe\nCREATE TABLE second (id INT PRIMARY KEY);")},
+				}
+				barrier    sync.WaitGroup
+				released   = make(chan struct{})
+				overlapped atomic.Int32
+			)
+			// Each migration waits for the other to start, which only
+			// happens in time if they run at the same time.
+			barrier.Add(len(migrations))
+			go func() {
+				barrier.Wait()
+				close(released)
+			}()
+			waitForOther := func(string) {
+				barrier.Done()
+				select {
+				case <-released:
+					overlapped.Add(1)
+				case <-time.After(5 * time.Second):
+				}
+			}
+
+			// Act
+			err := migrate.NewMigrator(db, migrations, migrate.WithParallelism(2), migrate.WithTransactionMode(migrate.NoTransaction), migrate.WithBeforeEach(waitForOther)).Migrate()
+
+			// Assert
+			assert.NoError(t, err)
+			assert.Equal(t, int32(2), overlapped.Load())
+			assert.True(t, repo.GetMigrationByName("001_first.sql").IsApplied)
+			assert.True(t, repo.GetMigrationByName("002_second.sql").IsApplied)
+		})
+
+		t.Run("rolls back a failing parallel-safe migration in its own transaction", func(t *testing.T) {
+			// Arrange
+			var (
+				db         = setupConcurrentTestDatabase(t)
+				repo       = newRepo(db)
+				migrations = fstest.MapFS{
+					"001_first.sql":  {Data: []byte("-- migrate:parallel-safe\nCREATE TABLE first (id INT PRIMARY KEY);")},
+					"002_second.sql": {Data: []byte("-- migrate:parallel-safe\nCREATE TABLE second (id INT PRIMARY KEY);\nINSERT INTO missing_table VALUES (1);")},
+				}
+			)
+
+			// Act
+			err := migrate.NewMigrator(db, migrations, migrate.WithParallelism(2), migrate.WithTransactionMode(migrate.NoTransaction)).Migrate()
+
+			// Assert
+			assert.Error(t, err)
+			assert.True(t, repo.GetMigrationByName("001_first.sql").IsApplied)
+			assert.Empty(t, repo.GetMigrationByName("002_second.sql").MigrationName)
+			_, err = db.Exec("SELECT id FROM second")
+			assert.Error(t, err)
+		})
+
+		t.Run("applies numeric versions in order", func(t *testing.T) {
+			// Arrange
+			var (
+				db   = migrate.SetupTestDatabase(t)
+				repo = newRepo(db)
+			)
+
+			// Act
+			err := sut(db, numericVersionMigrations)
+
+			// Assert
+			assert.NoError(t, err)
+			assert.True(t, repo.GetMigrationByName("2_add_users.sql").IsApplied)
+			assert.True(t, repo.GetMigrationByName("10_add_users_email_index.sql").IsApplied)
+		})
+
+		t.Run("should error when two migrations share a version", func(t *testing.T) {
+			// Arrange
+			var (
+				db         = migrate.SetupTestDatabase(t)
+				repo       = newRepo(db)
+				migrations = fstest.MapFS{
+					"001_create_users.sql": {Data: []byte("CREATE TABLE users (id INT PRIMARY KEY);")},
+					"1_create_orders.sql":  {Data: []byte("CREATE TABLE orders (id INT PRIMARY KEY);")},
+				}
+			)
+
+			// Act
+			err := sut(db, migrations)
+
+			// Assert
+			assert.ErrorIs(t, err, migrate.ErrDuplicateMigrationVersion)
+			migrationRows, err := repo.GetAllMigrations()
+			assert.NoError(t, err)
+			assert.Empty(t, migrationRows)
+		})
+
+		t.Run("should error when two migrations in different directories share a name", func(t *testing.T) {
+			// Arrange
+			var (
+				db         = migrate.SetupTestDatabase(t)
+				repo       = newRepo(db)
+				migrations = fstest.MapFS{
+					"a/001_init.sql": {Data: []byte("CREATE TABLE users (id INT PRIMARY KEY);")},
+					"b/001_init.sql": {Data: []byte("CREATE TABLE orders (id INT PRIMARY KEY);")},
+				}
+			)
+
+			// Act
+			err := sut(db, migrations)
+
+			// Assert
+			assert.ErrorIs(t, err, migrate.ErrDuplicateMigrationName)
+			assert.ErrorContains(t, err, `"a/001_init.sql" and "b/001_init.sql"`)
+			migrationRows, err := repo.GetAllMigrations()
+			assert.NoError(t, err)
+			assert.Empty(t, migrationRows)
+		})
+
+		t.Run("should error when a migration name contains a control character", func(t *testing.T) {
+			// Arrange
+			var (
+				db         = migrate.SetupTestDatabase(t)
+				migrations = fstest.MapFS{
+					"001_in\nit.sql": {Data: []byte("CREATE TABLE users (id INT PRIMARY KEY);")},
+				}
+			)
+
+			// Act
+			err := sut(db, migrations)
+
+			// Assert
+			assert.ErrorIs(t, err, migrate.ErrInvalidMigrationName)
+			assert.ErrorContains(t, err, "control character")
+		})
+
+		t.Run("should error when a migration name is too long for the table", func(t *testing.T) {
+			// Arrange
+			var (
+				db         = migrate.SetupTestDatabase(t)
+				migrations = fstest.MapFS{
+					"001_" + strings.Repeat("x", 256) + ".sql": {Data: []byte("CREATE TABLE users (id INT PRIMARY KEY);")},
+				}
+			)
+
+			// Act
+			err := sut(db, migrations)
+
+			// Assert
+			assert.ErrorIs(t, err, migrate.ErrInvalidMigrationName)
+			assert.ErrorContains(t, err, "at most 255 are allowed")
+		})
+
+		t.Run("recognizes renamed migrations by hash when identified by hash", func(t *testing.T) {
+			// Arrange
+			var (
+				db         = migrate.SetupTestDatabase(t)
+				repo       = newRepo(db)
+				content    = []byte("CREATE TABLE users (id INT PRIMARY KEY);")
+				migrations = fstest.MapFS{"001_init.sql": {Data: content}}
+			)
+			err := sut(db, migrations)
+			assert.NoError(t, err)
+			migrations = fstest.MapFS{"001_create_users.sql": {Data: content}}
+
+			// Act
+			err = migrate.NewMigrator(db, migrations, migrate.WithIdentityByHash(), migrate.WithOnMissing(migrate.MissingFail)).Migrate()
+
+			// Assert
+			assert.NoError(t, err)
+			migrationRows, err := repo.GetAllMigrations()
+			assert.NoError(t, err)
+			assert.Len(t, migrationRows, 1)
+			assert.True(t, repo.GetMigrationByName("001_init.sql").IsApplied)
+		})
+
+		t.Run("records migrations by path when keyed by path", func(t *testing.T) {
+			// Arrange
+			var (
+				db         = migrate.SetupTestDatabase(t)
+				repo       = newRepo(db)
+				migrations = fstest.MapFS{
+					"users/001_init.sql":  {Data: []byte("CREATE TABLE users (id INT PRIMARY KEY);")},
+					"orders/002_init.sql": {Data: []byte("CREATE TABLE orders (id INT PRIMARY KEY);")},
+				}
+			)
+
+			// Act
+			err := migrate.NewMigrator(db, migrations, migrate.WithKeyByPath()).Migrate()
+
+			// Assert
+			assert.NoError(t, err)
+			assert.True(t, repo.GetMigrationByName("users/001_init.sql").IsApplied)
+			assert.True(t, repo.GetMigrationByName("orders/002_init.sql").IsApplied)
+		})
+
+		t.Run("recognizes migrations recorded by file name when keyed by path", func(t *testing.T) {
+			// Arrange
+			var (
+				db         = migrate.SetupTestDatabase(t)
+				repo       = newRepo(db)
+				migrations = fstest.MapFS{
+					"users/001_init.sql": {Data: []byte("CREATE TABLE users (id INT PRIMARY KEY);")},
+				}
+			)
+			err := sut(db, migrations)
+			assert.NoError(t, err)
+			migrations["orders/002_init.sql"] = &fstest.MapFile{Data: []byte("CREATE TABLE orders (id INT PRIMARY KEY);")}
+
+			// Act
+			err = migrate.NewMigrator(db, migrations, migrate.WithKeyByPath()).Migrate()
+
+			// Assert
+			assert.NoError(t, err)
+			migrationRows, err := repo.GetAllMigrations()
+			assert.NoError(t, err)
+			assert.Len(t, migrationRows, 2)
+			assert.True(t, repo.GetMigrationByName("001_init.sql").IsApplied)
+			assert.True(t, repo.GetMigrationByName("orders/002_init.sql").IsApplied)
+		})
+
+		t.Run("should error when migration has no version", func(t *testing.T) {
+			// Arrange
+			var (
+				db         = migrate.SetupTestDatabase(t)
+				migrations = fstest.MapFS{
+					"create_users.sql": {Data: []byte("CREATE TABLE users (id INT PRIMARY KEY);")},
+				}
+			)
+
+			// Act
+			err := sut(db, migrations)
+
+			// Assert
+			assert.ErrorContains(t, err, "does not start with a numeric version")
+		})
+
+		t.Run("should error when migration version has non-ASCII digits", func(t *testing.T) {
+			// Arrange
+			var (
+				db         = migrate.SetupTestDatabase(t)
+				migrations = fstest.MapFS{
+					"١_create_users.sql": {Data: []byte("CREATE TABLE users (id INT PRIMARY KEY);")},
+				}
+			)
+
+			// Act
+			err := sut(db, migrations)
+
+			// Assert
+			assert.ErrorContains(t, err, "does not start with a numeric version")
+		})
+
+		t.Run("applies timestamp versions in order", func(t *testing.T) {
+			// Arrange
+			var (
+				db   = migrate.SetupTestDatabase(t)
+				repo = newRepo(db)
+			)
+
+			// Act
+			err := sut(db, timestampMigrations)
+
+			// Assert
+			assert.NoError(t, err)
+			migrations, err := repo.GetAllMigrations()
+			assert.NoError(t, err)
+			assert.Len(t, migrations, 2)
+			for _, migration := range migrations {
+				assert.True(t, migration.IsApplied)
+			}
+		})
+
+		t.Run("records transformed migration names", func(t *testing.T) {
+			// Arrange
+			var (
+				db          = migrate.SetupTestDatabase(t)
+				repo        = newRepo(db)
+				stripPrefix = func(filename string) string {
+					_, name, _ := strings.Cut(filename, "_")
+					return name
+				}
+				sut = func() error {
+					return migrate.NewMigrator(db, noErrorsMigration, migrate.WithNameTransformer(stripPrefix)).Migrate()
+				}
+			)
+
+			// Act
+			err := sut()
+			assert.NoError(t, err)
+			err = sut()
+
+			// Assert
+			assert.NoError(t, err)
+			migrations, err := repo.GetAllMigrations()
+			assert.NoError(t, err)
+			assert.Len(t, migrations, 2)
+			assert.True(t, repo.GetMigrationByName("test.sql").IsApplied)
+			assert.True(t, repo.GetMigrationByName("more_test.sql").IsApplied)
+		})
+
+		t.Run("calls after commit hook with applied migrations", func(t *testing.T) {
+			// Arrange
+			var (
+				db    = migrate.SetupTestDatabase(t)
+				calls [][]string
+				hook  = func(ctx context.Context, appliedMigrations []string) error {
+					calls = append(calls, appliedMigrations)
+					return nil
+				}
+				sut = func() error {
+					return migrate.NewMigrator(db, noErrorsMigration, migrate.WithAfterCommit(hook)).Migrate()
+				}
+			)
+
+			// Act
+			err := sut()
+			assert.NoError(t, err)
+			err = sut()
+
+			// Assert
+			assert.NoError(t, err)
+			assert.Equal(t, [][]string{{"001_test.sql", "002_more_test.sql"}, nil}, calls)
+		})
+
+		t.Run("calls hooks around each migration", func(t *testing.T) {
+			// Arrange
+			var (
+				db         = migrate.SetupTestDatabase(t)
+				calls      []string
+				beforeEach = func(name string) {
+					calls = append(calls, "before "+name)
+				}
+				afterEach = func(name string, err error, duration time.Duration) {
+					calls = append(calls, fmt.Sprintf("after %s %v %t", name, err, duration > 0))
+				}
+			)
+
+			// Act
+			err := migrate.NewMigrator(db, partiallyInvalidMigration, migrate.WithBeforeEach(beforeEach), migrate.WithAfterEach(afterEach)).Migrate()
+
+			// Assert
+			assert.ErrorIs(t, err, migrate.ErrMigrationFailed)
+			assert.Len(t, calls, 4)
+			assert.Equal(t, []string{"before 001_test.sql", "after 001_test.sql <nil> true", "before 002_invalid.sql"}, calls[:3])
+			assert.True(t, strings.HasPrefix(calls[3], "after 002_invalid.sql execute migration"))
+		})
+
+		t.Run("reports applied and failed migrations to the metrics", func(t *testing.T) {
+			// Arrange
+			var (
+				db      = migrate.SetupTestDatabase(t)
+				metrics = &spyMetrics{}
+			)
+
+			// Act
+			err := migrate.NewMigrator(db, partiallyInvalidMigration, migrate.WithMetrics(metrics)).Migrate()
+
+			// Assert
+			assert.ErrorIs(t, err, migrate.ErrMigrationFailed)
+			assert.Equal(t, []string{"001_test.sql"}, metrics.applied)
+			assert.Equal(t, []string{"002_invalid.sql"}, metrics.failed)
+		})
+
+		t.Run("fails the migration when a hook panics", func(t *testing.T) {
+			// Arrange
+			var (
+				db        = migrate.SetupTestDatabase(t)
+				repo      = newRepo(db)
+				afterEach = func(name string, err error, duration time.Duration) {
+					panic("boom")
+				}
+			)
+
+			// Act
+			err := migrate.NewMigrator(db, noErrorsMigration, migrate.WithAfterEach(afterEach), migrate.WithTransactionMode(migrate.PerMigration)).Migrate()
+
+			// Assert
+			assert.ErrorIs(t, err, migrate.ErrHookPanicked)
+			assert.ErrorContains(t, err, "boom")
+			migrations, err := repo.GetAllMigrations()
+			assert.NoError(t, err)
+			assert.Empty(t, migrations)
+		})
+
+		t.Run("does not run the migration when the before hook panics", func(t *testing.T) {
+			// Arrange
+			var (
+				db         = migrate.SetupTestDatabase(t)
+				repo       = newRepo(db)
+				beforeEach = func(name string) {
+					panic("boom")
+				}
+			)
+
+			// Act
+			err := migrate.NewMigrator(db, noErrorsMigration, migrate.WithBeforeEach(beforeEach)).Migrate()
+
+			// Assert
+			assert.ErrorIs(t, err, migrate.ErrHookPanicked)
+			migrations, err := repo.GetAllMigrations()
+			assert.NoError(t, err)
+			assert.Empty(t, migrations)
+		})
+
+		t.Run("logs the progress of a run", func(t *testing.T) {
+			// Arrange
+			var (
+				db     = migrate.SetupTestDatabase(t)
+				output bytes.Buffer
+				logger = slog.New(slog.NewTextHandler(&output, &slog.HandlerOptions{Level: slog.LevelDebug}))
+				sut    = func() error {
+					return migrate.NewMigrator(db, noErrorsMigration, migrate.WithLogger(logger)).Migrate()
+				}
+			)
+			err := sut()
+			assert.NoError(t, err)
+			firstRun := output.String()
+			output.Reset()
+
+			// Act
+			err = sut()
+
+			// Assert
+			assert.NoError(t, err)
+			assert.Contains(t, firstRun, `msg="applying migration" migration=001_test.sql`)
+			assert.Contains(t, firstRun, `msg="applied migration" migration=002_more_test.sql duration=`)
+			assert.Contains(t, firstRun, `msg="committed migrations" count=2`)
+			assert.Contains(t, output.String(), `msg="skipped already-applied migration" migration=001_test.sql`)
+			assert.Contains(t, output.String(), `msg="committed migrations" count=0`)
+		})
+
+		t.Run("does not call after commit hook when migration fails", func(t *testing.T) {
+			// Arrange
+			var (
+				db     = migrate.SetupTestDatabase(t)
+				called bool
+				hook   = func(ctx context.Context, appliedMigrations []string) error {
+					called = true
+					return nil
+				}
+			)
+
+			// Act
+			err := migrate.NewMigrator(db, invalidMigration, migrate.WithAfterCommit(hook)).Migrate()
+
+			// Assert
+			assert.ErrorIs(t, err, migrate.ErrMigrationFailed)
+			assert.False(t, called)
+		})
+
+		t.Run("should error when dirty migration exists", func(t *testing.T) {
+			// Arrange
+			var (
+				db = migrate.SetupTestDatabase(t)
+			)
+
+			_ = sut(db, invalidMigration)
+
+			// Act
+			err := sut(db, invalidMigration)
+
+			// Assert
+			assert.Error(t, err)
+			assert.ErrorIs(t, err, migrate.ErrDirtyMigration)
+		})
+
+		t.Run("applies migrations once when migrating concurrently", func(t *testing.T) {
+			// Arrange
+			var (
+				db   = migrate.SetupTestDatabase(t)
+				wg   sync.WaitGroup
+				errs = make([]error, 2)
+				runs int
+			)
+
+			// Act
+			for i := range errs {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					errs[i] = sut(db, concurrentMigrations)
+				}()
+			}
+			wg.Wait()
+
+			// Assert
+			assert.NoError(t, errs[0])
+			assert.NoError(t, errs[1])
+			err := db.QueryRow("SELECT COUNT(*) FROM migration_runs").Scan(&runs)
+			assert.NoError(t, err)
+			assert.Equal(t, 1, runs)
+		})
+
+		t.Run("fails fast when another process holds the lock", func(t *testing.T) {
+			locker, ok := testDialect().(migrate.Locker)
+			if !ok {
+				t.Skip("test database has no migration lock")
+			}
+
+			// Arrange
+			var (
+				db  = migrate.SetupTestDatabase(t)
+				ctx = context.Background()
+			)
+
+			conn, err := db.Conn(ctx)
+			assert.NoError(t, err)
+			defer conn.Close()
+			acquired, err := locker.Lock(ctx, conn, "migrations", true)
+			assert.NoError(t, err)
+			assert.True(t, acquired)
+
+			// Act
+			err = migrate.NewMigrator(db, noErrorsMigration, migrate.WithLockMode(migrate.LockFailFast)).Migrate()
+
+			// Assert
+			assert.ErrorIs(t, err, migrate.ErrMigrationLocked)
+		})
+
+		t.Run("stops waiting for the lock after the lock timeout", func(t *testing.T) {
+			locker, ok := testDialect().(migrate.Locker)
+			if !ok {
+				t.Skip("test database has no migration lock")
+			}
+
+			// Arrange
+			var (
+				db  = migrate.SetupTestDatabase(t)
+				ctx = context.Background()
+			)
+
+			conn, err := db.Conn(ctx)
+			assert.NoError(t, err)
+			defer conn.Close()
+			acquired, err := locker.Lock(ctx, conn, "migrations", true)
+			assert.NoError(t, err)
+			assert.True(t, acquired)
+
+			// Act
+			err = migrate.NewMigrator(db, noErrorsMigration, migrate.WithLockTimeout(100*time.Millisecond)).Migrate()
+
+			// Assert
+			assert.ErrorIs(t, err, migrate.ErrMigrationLocked)
+			assert.ErrorContains(t, err, "waited 100ms")
+		})
+
+		t.Run("force unlock ends the session holding the lock", func(t *testing.T) {
+			locker, ok := testDialect().(migrate.Locker)
+			if _, canBreak := testDialect().(migrate.LockBreaker); !ok || !canBreak {
+				t.Skip("test database has no migration lock")
+			}
+
+			// Arrange
+			var (
+				db  = migrate.SetupTestDatabase(t)
+				ctx = context.Background()
+			)
+
+			conn, err := db.Conn(ctx)
+			assert.NoError(t, err)
+			defer conn.Close()
+			acquired, err := locker.Lock(ctx, conn, "migrations", true)
+			assert.NoError(t, err)
+			assert.True(t, acquired)
+
+			// Act
+			err = migrate.NewMigrator(db, noErrorsMigration).ForceUnlock()
+
+			// Assert
+			assert.NoError(t, err)
+			err = migrate.NewMigrator(db, noErrorsMigration, migrate.WithLockMode(migrate.LockFailFast)).Migrate()
+			assert.NoError(t, err)
+		})
+	})
+}
+
+func BenchmarkMigrate(b *testing.B) {
+	// Every migration sleeps, standing in for slow schema changes on a big database.
+	var slowMigrations = fstest.MapFS{}
+	for i := range 8 {
+		slowMigrations[fmt.Sprintf("%03d_slow.sql", i)] = &fstest.MapFile{
+			Data: []byte("-- migrate:parallel-safe\nSELECT pg_sleep(0.05);"),
+		}
+	}
+
+	for _, parallelism := range []int{1, 4} {
+		b.Run(fmt.Sprintf("parallelism %d", parallelism), func(b *testing.B) {
+			for b.Loop() {
+				b.StopTimer()
+				db := migrate.SetupTestDatabase(b)
+				migrator := migrate.NewMigrator(db, slowMigrations, migrate.WithParallelism(parallelism))
+				b.StartTimer()
+
+				err := migrator.Migrate()
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkCheckAlteredMigrations(b *testing.B) {
+	// Hundreds of large applied migrations are hashed on every run. Compare
+	// sequential and concurrent hashing with -cpu 1,8.
+	var migrations = fstest.MapFS{}
+	for i := range 300 {
+		migrations[fmt.Sprintf("%03d_table.sql", i)] = &fstest.MapFile{
+			Data: []byte(fmt.Sprintf("-- %s\nCREATE TABLE table_%03d (id INT PRIMARY KEY);", strings.Repeat("x", 64<<10), i)),
+		}
+	}
+
+	var (
+		db       = migrate.SetupTestDatabase(b)
+		migrator = migrate.NewMigrator(db, migrations)
+	)
+	err := migrator.Migrate()
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	for b.Loop() {
+		_, err := migrator.DryRun()
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkMigrateUpToDate(b *testing.B) {
+	// Migrating a database that is already up to date is what every instance
+	// of an application does on boot.
+	var migrations = fstest.MapFS{}
+	for i := range 50 {
+		migrations[fmt.Sprintf("%03d_table.sql", i)] = &fstest.MapFile{
+			Data: []byte(fmt.Sprintf("CREATE TABLE table_%03d (id INT PRIMARY KEY);", i)),
+		}
+	}
+
+	for _, mode := range []migrate.TransactionMode{migrate.NoTransaction, migrate.AllInOne} {
+		b.Run(fmt.Sprintf("transaction mode %d", mode), func(b *testing.B) {
+			var (
+				db       = migrate.SetupTestDatabase(b)
+				migrator = migrate.NewMigrator(db, migrations, migrate.WithTransactionMode(mode))
+			)
+			err := migrator.Migrate()
+			if err != nil {
+				b.Fatal(err)
+			}
+
+			for b.Loop() {
+				err = migrator.Migrate()
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkFindKnownMigrations(b *testing.B) {
+	// Every file is looked up among the rows of the migrations table several
+	// times per run, which adds up for long-lived projects. Skipping the hash
+	// check leaves mostly the lookups.
+	var migrations = fstest.MapFS{}
+	for i := range 800 {
+		migrations[fmt.Sprintf("%03d_table.sql", i)] = &fstest.MapFile{
+			Data: []byte(fmt.Sprintf("CREATE TABLE table_%03d (id INT PRIMARY KEY);", i)),
+		}
+	}
+
+	var (
+		db       = migrate.SetupTestDatabase(b)
+		migrator = migrate.NewMigrator(db, migrations, migrate.WithSkipHashCheck())
+	)
+	err := migrator.Migrate()
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	for b.Loop() {
+		err = migrator.Migrate()
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestMigrateTo(t *testing.T) {
+	var newRepo = func(db *sql.DB) *test_data.TestRepo {
+		return test_data.NewRepo(db)
+	}
+
+	t.Run("MigrateTo", func(t *testing.T) {
+		t.Run("leaves migrations after a numeric target pending", func(t *testing.T) {
+			// Arrange
+			var (
+				db   = migrate.SetupTestDatabase(t)
+				repo = newRepo(db)
+			)
+
+			// Act
+			err := migrate.NewMigrator(db, numericVersionMigrations).MigrateTo("2")
+
+			// Assert
+			assert.NoError(t, err)
+			assert.True(t, repo.GetMigrationByName("2_add_users.sql").IsApplied)
+			assert.Empty(t, repo.GetMigrationByName("10_add_users_email_index.sql").MigrationName)
+		})
+
+		t.Run("applies the remaining migrations up to a file name target", func(t *testing.T) {
+			// Arrange
+			var (
+				db       = migrate.SetupTestDatabase(t)
+				repo     = newRepo(db)
+				migrator = migrate.NewMigrator(db, numericVersionMigrations)
+			)
+			err := migrator.MigrateTo("2")
+			assert.NoError(t, err)
+
+			// Act
+			err = migrator.MigrateTo("10_add_users_email_index.sql")
+
+			// Assert
+			assert.NoError(t, err)
+			migrations, err := repo.GetAllMigrations()
+			assert.NoError(t, err)
+			assert.Len(t, migrations, 2)
+		})
+
+		t.Run("should error when target does not exist", func(t *testing.T) {
+			// Arrange
+			var (
+				db   = migrate.SetupTestDatabase(t)
+				repo = newRepo(db)
+			)
+
+			// Act
+			err := migrate.NewMigrator(db, numericVersionMigrations).MigrateTo("3")
+
+			// Assert
+			assert.ErrorIs(t, err, migrate.ErrMigrationTargetNotFound)
+			migrations, err := repo.GetAllMigrations()
+			assert.Error(t, err)
+			assert.Empty(t, migrations)
+		})
+	})
+
+	t.Run("MigrateN", func(t *testing.T) {
+		t.Run("applies only the next n pending migrations", func(t *testing.T) {
+			// Arrange
+			var (
+				db   = migrate.SetupTestDatabase(t)
+				repo = newRepo(db)
+			)
+
+			// Act
+			err := migrate.NewMigrator(db, numericVersionMigrations).MigrateN(1)
+
+			// Assert
+			assert.NoError(t, err)
+			assert.True(t, repo.GetMigrationByName("2_add_users.sql").IsApplied)
+			assert.Empty(t, repo.GetMigrationByName("10_add_users_email_index.sql").MigrationName)
+		})
+
+		t.Run("continues with the migrations after the applied ones", func(t *testing.T) {
+			// Arrange
+			var (
+				db       = migrate.SetupTestDatabase(t)
+				repo     = newRepo(db)
+				migrator = migrate.NewMigrator(db, numericVersionMigrations)
+			)
+			err := migrator.MigrateN(1)
+			assert.NoError(t, err)
+
+			// Act
+			err = migrator.MigrateN(1)
+
+			// Assert
+			assert.NoError(t, err)
+			assert.True(t, repo.GetMigrationByName("10_add_users_email_index.sql").IsApplied)
+		})
+
+		t.Run("applies nothing when fewer than n migrations are pending", func(t *testing.T) {
+			// Arrange
+			var (
+				db   = migrate.SetupTestDatabase(t)
+				repo = newRepo(db)
+			)
+
+			// Act
+			err := migrate.NewMigrator(db, numericVersionMigrations).MigrateN(3)
+
+			// Assert
+			assert.NoError(t, err)
+			migrations, err := repo.GetAllMigrations()
+			assert.NoError(t, err)
+			assert.Empty(t, migrations)
+		})
+
+		t.Run("should error when n is negative", func(t *testing.T) {
+			// Arrange
+			var db = migrate.SetupTestDatabase(t)
+
+			// Act
+			err := migrate.NewMigrator(db, numericVersionMigrations).MigrateN(-1)
+
+			// Assert
+			assert.Error(t, err)
+		})
+	})
+}
+
+func TestMigrateResult(t *testing.T) {
+	t.Run("MigrateResult", func(t *testing.T) {
+		t.Run("reports the applied migrations", func(t *testing.T) {
+			// Arrange
+			var (
+				db = migrate.SetupTestDatabase(t)
+			)
+
+			// Act
+			result, err := migrate.NewMigrator(db, noErrorsMigration).MigrateResult()
+
+			// Assert
+			assert.NoError(t, err)
+			assert.Equal(t, migrate.Result{
+				Count:         2,
+				Applied:       []string{"001_test.sql", "002_more_test.sql"},
+				LatestVersion: 2,
+			}, result)
+		})
+
+		t.Run("reports the latest version when nothing is pending", func(t *testing.T) {
+			// Arrange
+			var (
+				db       = migrate.SetupTestDatabase(t)
+				migrator = migrate.NewMigrator(db, noErrorsMigration)
+			)
+			_, err := migrator.MigrateResult()
+			assert.NoError(t, err)
+
+			// Act
+			result, err := migrator.MigrateResult()
+
+			// Assert
+			assert.NoError(t, err)
+			assert.Equal(t, migrate.Result{LatestVersion: 2}, result)
+		})
+
+		t.Run("reports the migrations applied before a failure", func(t *testing.T) {
+			// Arrange
+			var (
+				db = migrate.SetupTestDatabase(t)
+			)
+
+			// Act
+			result, err := migrate.NewMigrator(db, partiallyInvalidMigration).MigrateResult()
+
+			// Assert
+			assert.ErrorIs(t, err, migrate.ErrMigrationFailed)
+			assert.Equal(t, migrate.Result{
+				Count:         1,
+				Applied:       []string{"001_test.sql"},
+				LatestVersion: 1,
+			}, result)
+		})
+	})
+}
+
+func TestMigrateConn(t *testing.T) {
+	var newRepo = func(db *sql.DB) *test_data.TestRepo {
+		return test_data.NewRepo(db)
+	}
+
+	t.Run("MigrateConn", func(t *testing.T) {
+		t.Run("applies the migrations on the given connection", func(t *testing.T) {
+			// Arrange
+			var (
+				db   = migrate.SetupTestDatabase(t)
+				repo = newRepo(db)
+				ctx  = context.Background()
+			)
+			conn, err := db.Conn(ctx)
+			assert.NoError(t, err)
+
+			// Act
+			err = migrate.NewMigrator(db, noErrorsMigration).MigrateConn(ctx, conn)
+
+			// Assert
+			assert.NoError(t, err)
+			_, err = conn.ExecContext(ctx, "SELECT 1")
+			assert.NoError(t, err, "connection should be left open")
+			assert.NoError(t, conn.Close())
+			migrations, err := repo.GetAllMigrations()
+			assert.NoError(t, err)
+			assert.Len(t, migrations, 2)
+		})
+	})
+}
+
+func TestMigrateTx(t *testing.T) {
+	var newRepo = func(db *sql.DB) *test_data.TestRepo {
+		return test_data.NewRepo(db)
+	}
+
+	t.Run("MigrateTx", func(t *testing.T) {
+		t.Run("applies the migrations when the transaction is committed", func(t *testing.T) {
+			// Arrange
+			var (
+				db   = migrate.SetupTestDatabase(t)
+				repo = newRepo(db)
+				ctx  = context.Background()
+			)
+			tx, err := db.BeginTx(ctx, nil)
+			assert.NoError(t, err)
+
+			// Act
+			err = migrate.NewMigrator(db, noErrorsMigration).MigrateTx(ctx, tx)
+
+			// Assert
+			assert.NoError(t, err)
+			assert.NoError(t, tx.Commit())
+			migrations, err := repo.GetAllMigrations()
+			assert.NoError(t, err)
+			assert.Len(t, migrations, 2)
+		})
+
+		t.Run("applies nothing when the transaction is rolled back", func(t *testing.T) {
+			// Arrange
+			var (
+				db   = migrate.SetupTestDatabase(t)
+				repo = newRepo(db)
+				ctx  = context.Background()
+			)
+			tx, err := db.BeginTx(ctx, nil)
+			assert.NoError(t, err)
+
+			// Act
+			err = migrate.NewMigrator(db, noErrorsMigration).MigrateTx(ctx, tx)
+
+			// Assert
+			assert.NoError(t, err)
+			assert.NoError(t, tx.Rollback())
+			migrations, _ := repo.GetAllMigrations()
+			assert.Empty(t, migrations)
+		})
+
+		t.Run("refuses no transaction migrations", func(t *testing.T) {
+			// Arrange
+			var (
+				db  = migrate.SetupTestDatabase(t)
+				ctx = context.Background()
+			)
+			tx, err := db.BeginTx(ctx, nil)
+			assert.NoError(t, err)
+			defer tx.Rollback()
+
+			// Act
+			err = migrate.NewMigrator(db, noTransactionMigrations).MigrateTx(ctx, tx)
+
+			// Assert
+			assert.ErrorContains(t, err, "no-transaction directive cannot be used with MigrateTx")
+		})
+	})
+}
+
+func TestSplitStatements(t *testing.T) {
+	t.Run("SplitStatements", func(t *testing.T) {
+		t.Run("splits at top level semicolons", func(t *testing.T) {
+			// Act
+			statements := migrate.SplitStatements("CREATE TABLE a (id INT);\n\nCREATE TABLE b (id INT);\n")
+
+			// Assert
+			assert.Equal(t, []string{"CREATE TABLE a (id INT)", "CREATE TABLE b (id INT)"}, statements)
+		})
+
+		t.Run("keeps semicolons in literals, identifiers and comments", func(t *testing.T) {
+			// Act
+			statements := migrate.SplitStatements("INSERT INTO \"a;b\" VALUES ('it''s; fine'); -- one; two\n/* three; */ SELECT 1")
+
+			// Assert
+			assert.Equal(t, []string{`INSERT INTO "a;b" VALUES ('it''s; fine')`, "-- one; two\n/* three; */ SELECT 1"}, statements)
+		})
+
+		t.Run("keeps dollar quoted blocks together", func(t *testing.T) {
+			// Arrange
+			var (
+				function = "CREATE FUNCTION f() RETURNS void AS $body$ BEGIN PERFORM 1; PERFORM $$;$$; END $body$ LANGUAGE plpgsql"
+			)
+
+			// Act
+			statements := migrate.SplitStatements(function + ";\nSELECT $1;")
+
+			// Assert
+			assert.Equal(t, []string{function, "SELECT $1"}, statements)
+		})
+
+		t.Run("splits the dollar quoted function migration into its statements", func(t *testing.T) {
+			// Arrange
+			var (
+				content, _ = dollarQuotedMigration.ReadFile("test_data/dollar_quoted_function/001_function.sql")
+			)
+
+			// Act
+			statements := migrate.SplitStatements(string(content))
+
+			// Assert
+			assert.Len(t, statements, 3)
+			assert.Contains(t, statements[1], "INSERT INTO test (id, name) VALUES (new_id, 'a;b');\nEND;\n$$ LANGUAGE plpgsql")
+		})
+
+		t.Run("drops statements without code", func(t *testing.T) {
+			// Act
+			statements := migrate.SplitStatements("-- migrate:no-transaction\n;\n  ; /* nothing */")
+
+			// Assert
+			assert.Empty(t, statements)
+		})
+	})
+}
+
+func TestRegister(t *testing.T) {
+	var newRepo = func(db *sql.DB) *test_data.TestRepo {
+		return test_data.NewRepo(db)
+	}
+
+	t.Run("Register", func(t *testing.T) {
+		t.Run("applies Go migrations in order with the SQL files", func(t *testing.T) {
+			// Arrange
+			var (
+				db       = migrate.SetupTestDatabase(t)
+				repo     = newRepo(db)
+				migrator = migrate.NewMigrator(db, noErrorsMigration)
+				count    int
+			)
+			migrator.Register("003_seed_test", func(ctx context.Context, tx *sql.Tx) error {
+				_, err := tx.ExecContext(ctx, "INSERT INTO test (id, name) VALUES (1, 'alice')")
+				return err
+			})
+
+			// Act
+			result, err := migrator.MigrateResult()
+
+			// Assert
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"001_test.sql", "002_more_test.sql", "003_seed_test"}, result.Applied)
+			assert.True(t, repo.GetMigrationByName("003_seed_test").IsApplied)
+			err = db.QueryRow("SELECT COUNT(*) FROM test").Scan(&count)
+			assert.NoError(t, err)
+			assert.Equal(t, 1, count)
+			err = migrator.Migrate()
+			assert.NoError(t, err)
+		})
+
+		t.Run("rolls back a failing Go migration and leaves it dirty", func(t *testing.T) {
+			// Arrange
+			var (
+				db       = migrate.SetupTestDatabase(t)
+				repo     = newRepo(db)
+				migrator = migrate.NewMigrator(db, noErrorsMigration)
+				count    int
+			)
+			migrator.Register("003_seed_test", func(ctx context.Context, tx *sql.Tx) error {
+				_, err := tx.ExecContext(ctx, "INSERT INTO test (id, name) VALUES (1, 'alice')")
+				assert.NoError(t, err)
+				return fmt.Errorf("backfill failed")
+			})
+
+			// Act
+			err := migrator.Migrate()
+
+			// Assert
+			assert.ErrorIs(t, err, migrate.ErrMigrationFailed)
+			assert.ErrorContains(t, err, "backfill failed")
+			assert.True(t, repo.GetMigrationByName("003_seed_test").IsDirty)
+			err = db.QueryRow("SELECT COUNT(*) FROM test").Scan(&count)
+			assert.NoError(t, err)
+			assert.Equal(t, 0, count)
+		})
+
+		t.Run("should error when a migration exceeds the per-migration timeout", func(t *testing.T) {
+			// Arrange
+			var (
+				db       = migrate.SetupTestDatabase(t)
+				repo     = newRepo(db)
+				migrator = migrate.NewMigrator(db, noErrorsMigration, migrate.WithPerMigrationTimeout(10*time.Millisecond))
+			)
+			migrator.Register("003_slow", func(ctx context.Context, tx *sql.Tx) error {
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case <-time.After(time.Second):
+					return nil
+				}
+			})
+
+			// Act
+			err := migrator.Migrate()
+
+			// Assert
+			assert.ErrorIs(t, err, migrate.ErrMigrationFailed)
+			assert.ErrorIs(t, err, context.DeadlineExceeded)
+			assert.ErrorContains(t, err, `execute migration "003_slow": exceeded per-migration timeout of 10ms`)
+			assert.True(t, repo.GetMigrationByName("002_more_test.sql").IsApplied)
+			assert.True(t, repo.GetMigrationByName("003_slow").IsDirty)
+		})
+
+		t.Run("should error when a Go migration shares a name with a file", func(t *testing.T) {
+			// Arrange
+			var (
+				db       = migrate.SetupTestDatabase(t)
+				migrator = migrate.NewMigrator(db, noErrorsMigration)
+			)
+			migrator.Register("001_test.sql", func(ctx context.Context, tx *sql.Tx) error {
+				return nil
+			})
+
+			// Act
+			err := migrator.Migrate()
+
+			// Assert
+			assert.ErrorIs(t, err, migrate.ErrDuplicateMigrationName)
+		})
+	})
+}
+
+func TestRollback(t *testing.T) {
+	var newRepo = func(db *sql.DB) *test_data.TestRepo {
+		return test_data.NewRepo(db)
+	}
+
+	t.Run("Rollback", func(t *testing.T) {
+		t.Run("should error when steps is negative", func(t *testing.T) {
+			// Arrange
+			var (
+				db       = migrate.SetupTestDatabase(t)
+				repo     = newRepo(db)
+				migrator = migrate.NewMigrator(db, downMigrations)
+			)
+			err := migrator.Migrate()
+			assert.NoError(t, err)
+
+			// Act
+			err = migrator.Rollback(-1)
+
+			// Assert
+			assert.Error(t, err)
+			assert.True(t, repo.GetMigrationByName("001_create_users.sql").IsApplied)
+			assert.True(t, repo.GetMigrationByName("002_add_user_email.sql").IsApplied)
+		})
+
+		t.Run("rolls back the last applied migration", func(t *testing.T) {
+			// Arrange
+			var (
+				db       = migrate.SetupTestDatabase(t)
+				repo     = newRepo(db)
+				migrator = migrate.NewMigrator(db, downMigrations)
+			)
+			err := migrator.Migrate()
+			assert.NoError(t, err)
+
+			// Act
+			err = migrator.Rollback(1)
+
+			// Assert
+			assert.NoError(t, err)
+			assert.True(t, repo.GetMigrationByName("001_create_users.sql").IsApplied)
+			rolledBack := repo.GetMigrationByName("002_add_user_email.sql")
+			assert.False(t, rolledBack.IsApplied)
+			assert.False(t, rolledBack.IsDirty)
+			assert.True(t, rolledBack.AppliedAt.IsZero())
+		})
+
+		t.Run("rolls back with down.sql in the directory layout", func(t *testing.T) {
+			// Arrange
+			var (
+				db       = migrate.SetupTestDatabase(t)
+				repo     = newRepo(db)
+				migrator = migrate.NewMigrator(db, directoryLayoutMigrations, migrate.WithLayout(migrate.DirectoryLayout))
+			)
+			err := migrator.Migrate()
+			assert.NoError(t, err)
+
+			// Act
+			err = migrator.Rollback(1)
+
+			// Assert
+			assert.NoError(t, err)
+			assert.True(t, repo.GetMigrationByName("001_create_users").IsApplied)
+			assert.False(t, repo.GetMigrationByName("002_add_user_email").IsApplied)
+		})
+
+		t.Run("can migrate again after rolling back", func(t *testing.T) {
+			// Arrange
+			var (
+				db       = migrate.SetupTestDatabase(t)
+				repo     = newRepo(db)
+				migrator = migrate.NewMigrator(db, downMigrations)
+			)
+			err := migrator.Migrate()
+			assert.NoError(t, err)
+			err = migrator.Rollback(2)
+			assert.NoError(t, err)
+
+			// Act
+			err = migrator.Migrate()
+
+			// Assert
+			assert.NoError(t, err)
+			migrations, err := repo.GetAllMigrations()
+			assert.NoError(t, err)
+			assert.Len(t, migrations, 2)
+			for _, migration := range migrations {
+				assert.True(t, migration.IsApplied)
+			}
+		})
+
+		t.Run("rolls back nothing when a later down migration fails in a transaction", func(t *testing.T) {
+			// Arrange
+			var (
+				db         = migrate.SetupTestDatabase(t)
+				repo       = newRepo(db)
+				migrations = fstest.MapFS{
+					"001_users.sql":       {Data: []byte("CREATE TABLE users (id INT PRIMARY KEY);")},
+					"001_users.down.sql":  {Data: []byte("DROP TABLE missing_table;")},
+					"002_orders.sql":      {Data: []byte("CREATE TABLE orders (id INT PRIMARY KEY);")},
+					"002_orders.down.sql": {Data: []byte("DROP TABLE orders;")},
+				}
+				migrator = migrate.NewMigrator(db, migrations, migrate.WithTransactionMode(migrate.PerMigration))
+			)
+			err := migrator.Migrate()
+			assert.NoError(t, err)
+
+			// Act
+			err = migrator.Rollback(2)
+
+			// Assert
+			var migrationErr *migrate.MigrationError
+			assert.ErrorAs(t, err, &migrationErr)
+			assert.Equal(t, "001_users.sql", migrationErr.Migration)
+			for _, name := range []string{"001_users.sql", "002_orders.sql"} {
+				migration := repo.GetMigrationByName(name)
+				assert.True(t, migration.IsApplied)
+				assert.False(t, migration.IsDirty)
+			}
+			_, err = db.Exec("SELECT id FROM orders")
+			assert.NoError(t, err)
+		})
+
+		t.Run("rolls back outside a transaction with a no-transaction down migration", func(t *testing.T) {
+			// Arrange
+			var (
+				db         = migrate.SetupTestDatabase(t)
+				repo       = newRepo(db)
+				migrations = fstest.MapFS{
+					"001_users.sql":       {Data: []byte("CREATE TABLE users (id INT PRIMARY KEY);")},
+					"001_users.down.sql":  {Data: []byte("-- migrate:no-transaction\nDROP TABLE missing_table;")},
+					"002_orders.sql":      {Data: []byte("CREATE TABLE orders (id INT PRIMARY KEY);")},
+					"002_orders.down.sql": {Data: []byte("DROP TABLE orders;")},
+				}
+				migrator = migrate.NewMigrator(db, migrations, migrate.WithTransactionMode(migrate.PerMigration))
+			)
+			err := migrator.Migrate()
+			assert.NoError(t, err)
+
+			// Act
+			err = migrator.Rollback(2)
+
+			// Assert
+			assert.Error(t, err)
+			assert.False(t, repo.GetMigrationByName("002_orders.sql").IsApplied)
+			assert.True(t, repo.GetMigrationByName("001_users.sql").IsDirty)
+		})
+
+		t.Run("keeps when a migration was applied when its down migration fails", func(t *testing.T) {
+			// Arrange
+			var (
+				db         = migrate.SetupTestDatabase(t)
+				appliedAt  = time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC)
+				migrations = fstest.MapFS{
+					"001_users.sql":      {Data: []byte("CREATE TABLE users (id INT PRIMARY KEY);")},
+					"001_users.down.sql": {Data: []byte("DROP TABLE missing_table;")},
+				}
+			)
+			err := migrate.NewMigrator(db, migrations, migrate.WithClock(func() time.Time { return appliedAt })).Migrate()
+			assert.NoError(t, err)
+
+			// Act
+			err = migrate.NewMigrator(db, migrations, migrate.WithClock(func() time.Time { return appliedAt.Add(time.Hour) })).Rollback(1)
+
+			// Assert
+			assert.Error(t, err)
+			statuses, err := migrate.NewMigrator(db, migrations).Status()
+			assert.NoError(t, err)
+			assert.Len(t, statuses, 1)
+			assert.True(t, statuses[0].Dirty)
+			assert.True(t, appliedAt.Equal(statuses[0].AppliedAt), "applied at %s", statuses[0].AppliedAt)
+		})
+
+		t.Run("should error when down migration is missing", func(t *testing.T) {
+			// Arrange
+			var (
+				db       = migrate.SetupTestDatabase(t)
+				repo     = newRepo(db)
+				migrator = migrate.NewMigrator(db, noErrorsMigration)
+			)
+			err := migrator.Migrate()
+			assert.NoError(t, err)
+
+			// Act
+			err = migrator.Rollback(1)
+
+			// Assert
+			assert.ErrorIs(t, err, migrate.ErrNoDownMigration)
+			assert.True(t, repo.GetMigrationByName("002_more_test.sql").IsApplied)
+		})
+	})
+}
+
+func TestReset(t *testing.T) {
+	t.Run("Reset", func(t *testing.T) {
+		t.Run("should error without WithAllowReset", func(t *testing.T) {
+			// Arrange
+			var (
+				db   = migrate.SetupTestDatabase(t)
+				repo = test_data.NewRepo(db)
+			)
+			err := migrate.NewMigrator(db, noErrorsMigration).Migrate()
+			assert.NoError(t, err)
+
+			// Act
+			err = migrate.NewMigrator(db, noErrorsMigration).Reset()
+
+			// Assert
+			assert.ErrorIs(t, err, migrate.ErrResetNotAllowed)
+			assert.True(t, repo.GetMigrationByName("001_test.sql").IsApplied)
+		})
+
+		t.Run("forgets every migration so they are applied again", func(t *testing.T) {
+			// Arrange
+			var (
+				db       = migrate.SetupTestDatabase(t)
+				repo     = test_data.NewRepo(db)
+				migrator = migrate.NewMigrator(db, noErrorsMigration, migrate.WithAllowReset())
+			)
+			err := migrator.Migrate()
+			assert.NoError(t, err)
+
+			// Act
+			err = migrator.Reset()
+
+			// Assert
+			assert.NoError(t, err)
+			migrations, err := repo.GetAllMigrations()
+			assert.NoError(t, err)
+			assert.Empty(t, migrations)
+			result, err := migrator.MigrateResult()
+			assert.NoError(t, err)
+			assert.Equal(t, 2, result.Count)
+		})
+
+		t.Run("rolls back migrations with down migrations first", func(t *testing.T) {
+			// Arrange
+			var (
+				db       = migrate.SetupTestDatabase(t)
+				migrator = migrate.NewMigrator(db, downMigrations, migrate.WithAllowReset())
+			)
+			err := migrator.Migrate()
+			assert.NoError(t, err)
+
+			// Act
+			err = migrator.Reset()
+
+			// Assert
+			assert.NoError(t, err)
+			_, err = db.Exec("SELECT * FROM users")
+			assert.Error(t, err)
+			err = migrator.Migrate()
+			assert.NoError(t, err)
+		})
+	})
+}
+
+func TestHashOf(t *testing.T) {
+	t.Run("HashOf", func(t *testing.T) {
+		t.Run("returns the hash stored when applying the migration", func(t *testing.T) {
+			// Arrange
+			var (
+				db   = migrate.SetupTestDatabase(t)
+				repo = test_data.NewRepo(db)
+			)
+			err := migrate.NewMigrator(db, noErrorsMigration).Migrate()
+			assert.NoError(t, err)
+
+			// Act
+			hash, err := migrate.NewMigrator(nil, noErrorsMigration).HashOf("002_more_test.sql")
+
+			// Assert
+			assert.NoError(t, err)
+			assert.Equal(t, repo.GetMigrationByName("002_more_test.sql").MigrationHash, hash)
+		})
+
+		t.Run("normalizes line endings like the altered check", func(t *testing.T) {
+			// Arrange
+			var (
+				crlf = fstest.MapFS{
+					"001_test.sql": {Data: []byte("CREATE TABLE test (id INT);\r\n")},
+				}
+				lf = fstest.MapFS{
+					"001_test.sql": {Data: []byte("CREATE TABLE test (id INT);\n")},
+				}
+			)
+
+			// Act
+			crlfHash, crlfErr := migrate.NewMigrator(nil, crlf, migrate.WithNormalizeLineEndings(true)).HashOf("001_test.sql")
+			lfHash, lfErr := migrate.NewMigrator(nil, lf, migrate.WithNormalizeLineEndings(true)).HashOf("001_test.sql")
+
+			// Assert
+			assert.NoError(t, crlfErr)
+			assert.NoError(t, lfErr)
+			assert.Equal(t, lfHash, crlfHash)
+		})
+
+		t.Run("should error when the migration does not exist", func(t *testing.T) {
+			// Act
+			_, err := migrate.NewMigrator(nil, noErrorsMigration).HashOf("003_missing.sql")
+
+			// Assert
+			assert.ErrorIs(t, err, migrate.ErrMigrationTargetNotFound)
+		})
+	})
+}
+
+func TestLockFile(t *testing.T) {
+	var generateLock = func(t *testing.T, migrations fs.FS) fstest.MapFS {
+		var lock bytes.Buffer
+		err := migrate.NewMigrator(nil, migrations).GenerateLock(&lock)
+		assert.NoError(t, err)
+
+		return fstest.MapFS{migrate.LockFileName: {Data: lock.Bytes()}}
+	}
+
+	t.Run("GenerateLock", func(t *testing.T) {
+		t.Run("lists the hash of every migration", func(t *testing.T) {
+			// Arrange
+			var (
+				lock     bytes.Buffer
+				migrator = migrate.NewMigrator(nil, noErrorsMigration)
+			)
+			hash, err := migrator.HashOf("001_test.sql")
+			assert.NoError(t, err)
+
+			// Act
+			err = migrator.GenerateLock(&lock)
+
+			// Assert
+			assert.NoError(t, err)
+			assert.Contains(t, lock.String(), hash+"  001_test.sql\n")
+			assert.Contains(t, lock.String(), "002_more_test.sql")
+		})
+	})
+
+	t.Run("WithLockFile", func(t *testing.T) {
+		t.Run("applies migrations matching the lock file", func(t *testing.T) {
+			// Arrange
+			var (
+				db   = migrate.SetupTestDatabase(t)
+				repo = test_data.NewRepo(db)
+				lock = generateLock(t, noErrorsMigration)
+			)
+
+			// Act
+			err := migrate.NewMigrator(db, noErrorsMigration, migrate.WithLockFile(lock)).Migrate()
+
+			// Assert
+			assert.NoError(t, err)
+			assert.True(t, repo.GetMigrationByName("002_more_test.sql").IsApplied)
+		})
+
+		t.Run("should error before touching the database when a migration was edited", func(t *testing.T) {
+			// Arrange
+			var (
+				db   = migrate.SetupTestDatabase(t)
+				repo = test_data.NewRepo(db)
+				lock = generateLock(t, changingMigrations)
+			)
+
+			// Act
+			err := migrate.NewMigrator(db, changingMigrationsChanged, migrate.WithLockFile(lock)).Migrate()
+
+			// Assert
+			assert.ErrorIs(t, err, migrate.ErrLockFileMismatch)
+			_, err = repo.GetAllMigrations()
+			assert.Error(t, err)
+		})
+
+		t.Run("should error when a migration is not listed", func(t *testing.T) {
+			// Arrange
+			var (
+				lock       = generateLock(t, fstest.MapFS{"001_test.sql": {Data: []byte("SELECT 1;")}})
+				migrations = fstest.MapFS{
+					"001_test.sql": {Data: []byte("SELECT 1;")},
+					"002_test.sql": {Data: []byte("SELECT 2;")},
+				}
+			)
+
+			// Act
+			err := migrate.NewMigrator(nil, migrations, migrate.WithLockFile(lock)).VerifyLock()
+
+			// Assert
+			assert.ErrorIs(t, err, migrate.ErrLockFileMismatch)
+			assert.ErrorContains(t, err, "002_test.sql")
+		})
+
+		t.Run("should error when a listed migration is missing", func(t *testing.T) {
+			// Arrange
+			var lock = generateLock(t, noErrorsMigration)
+
+			// Act
+			err := migrate.NewMigrator(nil, fstest.MapFS{"001_test.sql": {Data: []byte("SELECT 1;")}}, migrate.WithLockFile(lock)).VerifyLock()
+
+			// Assert
+			assert.ErrorIs(t, err, migrate.ErrLockFileMismatch)
+			assert.ErrorContains(t, err, "002_more_test.sql")
+		})
+	})
+}
+
+func TestRepair(t *testing.T) {
+	var newRepo = func(db *sql.DB) *test_data.TestRepo {
+		return test_data.NewRepo(db)
+	}
+
+	t.Run("Repair", func(t *testing.T) {
+		t.Run("stores the hash of altered migrations", func(t *testing.T) {
+			// Arrange
+			var (
+				db   = migrate.SetupTestDatabase(t)
+				repo = newRepo(db)
+			)
+			err := migrate.NewMigrator(db, changingMigrations).Migrate()
+			assert.NoError(t, err)
+			before := repo.GetMigrationByName("001_test.sql")
+
+			// Act
+			err = migrate.NewMigrator(db, changingMigrationsChanged).Repair()
+
+			// Assert
+			assert.NoError(t, err)
+			after := repo.GetMigrationByName("001_test.sql")
+			assert.NotEqual(t, before.MigrationHash, after.MigrationHash)
+			assert.True(t, after.IsApplied)
+			assert.True(t, before.AppliedAt.Equal(after.AppliedAt))
+			err = migrate.NewMigrator(db, changingMigrationsChanged).Migrate()
+			assert.NoError(t, err)
+		})
+
+		t.Run("does not apply pending migrations", func(t *testing.T) {
+			// Arrange
+			var (
+				db   = migrate.SetupTestDatabase(t)
+				repo = newRepo(db)
+			)
+
+			// Act
+			err := migrate.NewMigrator(db, noErrorsMigration).Repair()
+
+			// Assert
+			assert.NoError(t, err)
+			migrations, err := repo.GetAllMigrations()
+			assert.NoError(t, err)
+			assert.Empty(t, migrations)
+		})
+	})
+}
+
+func TestResolveDirty(t *testing.T) {
+	var newRepo = func(db *sql.DB) *test_data.TestRepo {
+		return test_data.NewRepo(db)
+	}
+
+	t.Run("marks a dirty migration as applied so it is not run again", func(t *testing.T) {
+		// Arrange
+		var (
+			db   = migrate.SetupTestDatabase(t)
+			repo = newRepo(db)
+		)
+		err := migrate.NewMigrator(db, partiallyInvalidMigration).Migrate()
+		assert.Error(t, err)
+
+		// Act
+		err = migrate.NewMigrator(db, partiallyInvalidMigration).ResolveDirty("002_invalid.sql", true)
+
+		// Assert
+		assert.NoError(t, err)
+		migration := repo.GetMigrationByName("002_invalid.sql")
+		assert.True(t, migration.IsApplied)
+		assert.False(t, migration.IsDirty)
+		err = migrate.NewMigrator(db, partiallyInvalidMigration).Migrate()
+		assert.NoError(t, err)
+	})
+
+	t.Run("marks a dirty migration as pending so it is run again", func(t *testing.T) {
+		// Arrange
+		var (
+			db   = migrate.SetupTestDatabase(t)
+			repo = newRepo(db)
+		)
+		err := migrate.NewMigrator(db, partiallyInvalidMigration).Migrate()
+		assert.Error(t, err)
+
+		// Act
+		err = migrate.NewMigrator(db, partiallyInvalidMigration).ResolveDirty("002_invalid.sql", false)
+
+		// Assert
+		assert.NoError(t, err)
+		migration := repo.GetMigrationByName("002_invalid.sql")
+		assert.False(t, migration.IsApplied)
+		assert.False(t, migration.IsDirty)
+		err = migrate.NewMigrator(db, partiallyInvalidMigration).Migrate()
+		assert.ErrorIs(t, err, migrate.ErrMigrationFailed)
+	})
+
+	t.Run("should error when the migration is not dirty", func(t *testing.T) {
+		// Arrange
+		var (
+			db = migrate.SetupTestDatabase(t)
+		)
+		err := migrate.NewMigrator(db, noErrorsMigration).Migrate()
+		assert.NoError(t, err)
+
+		// Act
+		err = migrate.NewMigrator(db, noErrorsMigration).ResolveDirty("001_test.sql", true)
+
+		// Assert
+		assert.ErrorContains(t, err, "is not dirty")
+	})
+
+	t.Run("should error when the migration is unknown", func(t *testing.T) {
+		// Arrange
+		var (
+			db = migrate.SetupTestDatabase(t)
+		)
+
+		// Act
+		err := migrate.NewMigrator(db, noErrorsMigration).ResolveDirty("001_test.sql", true)
+
+		// Assert
+		assert.ErrorIs(t, err, migrate.ErrMigrationTargetNotFound)
+	})
+}
+
+func TestApplyOne(t *testing.T) {
+	t.Run("ApplyOne", func(t *testing.T) {
+		t.Run("applies only the named migration", func(t *testing.T) {
+			// Arrange
+			var (
+				db   = migrate.SetupTestDatabase(t)
+				repo = test_data.NewRepo(db)
+			)
+
+			// Act
+			err := migrate.NewMigrator(db, noErrorsMigration).ApplyOne("002_more_test.sql")
+
+			// Assert
+			assert.NoError(t, err)
+			migrations, err := repo.GetAllMigrations()
+			assert.NoError(t, err)
+			assert.Len(t, migrations, 1)
+			assert.True(t, repo.GetMigrationByName("002_more_test.sql").IsApplied)
+		})
+
+		t.Run("should error when the migration is already applied", func(t *testing.T) {
+			// Arrange
+			var (
+				db = migrate.SetupTestDatabase(t)
+			)
+			err := migrate.NewMigrator(db, noErrorsMigration).Migrate()
+			assert.NoError(t, err)
+
+			// Act
+			err = migrate.NewMigrator(db, noErrorsMigration).ApplyOne("1")
+
+			// Assert
+			assert.ErrorIs(t, err, migrate.ErrMigrationsAlreadyApplied)
+		})
+
+		t.Run("should error when an applied migration has been altered", func(t *testing.T) {
+			// Arrange
+			var (
+				db         = migrate.SetupTestDatabase(t)
+				migrations = fstest.MapFS{
+					"001_test.sql":      {Data: []byte("CREATE TABLE test (id INT PRIMARY KEY);")},
+					"002_more_test.sql": {Data: []byte("CREATE TABLE more_test (id INT PRIMARY KEY);")},
+				}
+			)
+			err := migrate.NewMigrator(db, migrations).ApplyOne("001_test.sql")
+			assert.NoError(t, err)
+			migrations["001_test.sql"] = &fstest.MapFile{Data: []byte("CREATE TABLE test (id BIGINT PRIMARY KEY);")}
+
+			// Act
+			err = migrate.NewMigrator(db, migrations).ApplyOne("002_more_test.sql")
+
+			// Assert
+			assert.ErrorIs(t, err, migrate.ErrMigrationFileChanged)
+		})
+
+		t.Run("should error when no migration matches", func(t *testing.T) {
+			// Arrange
+			var (
+				db = migrate.SetupTestDatabase(t)
+			)
+
+			// Act
+			err := migrate.NewMigrator(db, noErrorsMigration).ApplyOne("003_unknown.sql")
+
+			// Assert
+			assert.ErrorIs(t, err, migrate.ErrMigrationTargetNotFound)
+		})
+	})
+}
+
+func TestBaseline(t *testing.T) {
+	var newRepo = func(db *sql.DB) *test_data.TestRepo {
+		return test_data.NewRepo(db)
+	}
+
+	t.Run("Baseline", func(t *testing.T) {
+		t.Run("marks migrations up to the target applied without executing them", func(t *testing.T) {
+			// Arrange
+			var (
+				db   = migrate.SetupTestDatabase(t)
+				repo = newRepo(db)
+			)
+
+			// Act
+			err := migrate.NewMigrator(db, noErrorsMigration).Baseline("001_test.sql")
+
+			// Assert
+			assert.NoError(t, err)
+			baselined := repo.GetMigrationByName("001_test.sql")
+			assert.True(t, baselined.IsApplied)
+			assert.False(t, baselined.IsDirty)
+			assert.NotEmpty(t, baselined.MigrationHash)
+			assert.Empty(t, repo.GetMigrationByName("002_more_test.sql").MigrationName)
+			_, err = db.Exec("SELECT id FROM test")
+			assert.Error(t, err, "baselined migration should not have been executed")
+		})
+
+		t.Run("should error when migrations are already applied", func(t *testing.T) {
+			// Arrange
+			var (
+				db       = migrate.SetupTestDatabase(t)
+				migrator = migrate.NewMigrator(db, noErrorsMigration)
+			)
+			err := migrator.MigrateTo("1")
+			assert.NoError(t, err)
+
+			// Act
+			err = migrator.Baseline("2")
+
+			// Assert
+			assert.ErrorIs(t, err, migrate.ErrMigrationsAlreadyApplied)
+		})
+
+		t.Run("should error when target does not exist", func(t *testing.T) {
+			// Arrange
+			var (
+				db = migrate.SetupTestDatabase(t)
+			)
+
+			// Act
+			err := migrate.NewMigrator(db, noErrorsMigration).Baseline("3")
+
+			// Assert
+			assert.ErrorIs(t, err, migrate.ErrMigrationTargetNotFound)
+		})
+	})
+}
+
+func TestStatus(t *testing.T) {
+	t.Run("Status", func(t *testing.T) {
+		t.Run("reports applied, pending and missing migrations", func(t *testing.T) {
+			// Arrange
+			var (
+				db         = migrate.SetupTestDatabase(t)
+				migrations = fstest.MapFS{
+					"001_test.sql": {Data: []byte("CREATE TABLE IF NOT EXISTS test (id INT PRIMARY KEY);")},
+					"003_new.sql":  {Data: []byte("CREATE TABLE IF NOT EXISTS new (id INT PRIMARY KEY);")},
+				}
+			)
+			err := migrate.NewMigrator(db, noErrorsMigration).Migrate()
+			assert.NoError(t, err)
+
+			// Act
+			statuses, err := migrate.NewMigrator(db, migrations).Status()
+
+			// Assert
+			assert.NoError(t, err)
+			assert.Len(t, statuses, 3)
+			assert.Equal(t, "001_test.sql", statuses[0].Name)
+			assert.Equal(t, migrate.MigrationApplied, statuses[0].State)
+			assert.True(t, statuses[0].Applied)
+			assert.False(t, statuses[0].AppliedAt.IsZero())
+			assert.Equal(t, "003_new.sql", statuses[1].Name)
+			assert.Equal(t, migrate.MigrationPending, statuses[1].State)
+			assert.False(t, statuses[1].Applied)
+			assert.NotEmpty(t, statuses[1].Hash)
+			assert.Equal(t, "002_more_test.sql", statuses[2].Name)
+			assert.Equal(t, migrate.MigrationMissing, statuses[2].State)
+			assert.True(t, statuses[2].Applied)
+		})
+
+		t.Run("reports who applied the migrations", func(t *testing.T) {
+			// Arrange
+			var (
+				db = migrate.SetupTestDatabase(t)
+			)
+			err := migrate.NewMigrator(db, noErrorsMigration, migrate.WithAppliedBy("v1.2.3")).MigrateTo("1")
+			assert.NoError(t, err)
+
+			// Act
+			statuses, err := migrate.NewMigrator(db, noErrorsMigration).Status()
+
+			// Assert
+			assert.NoError(t, err)
+			assert.Len(t, statuses, 2)
+			assert.Equal(t, "v1.2.3", statuses[0].AppliedBy)
+			assert.Empty(t, statuses[1].AppliedBy)
+		})
+
+		t.Run("reports no duration for migrations applied before durations were recorded", func(t *testing.T) {
+			// Arrange
+			var (
+				db = migrate.SetupTestDatabase(t)
+			)
+			_, err := db.Exec("CREATE TABLE migrations (migration_name VARCHAR(255) PRIMARY KEY, migration_hash VARCHAR(64), is_applied BOOLEAN NOT NULL DEFAULT FALSE, is_dirty BOOLEAN NOT NULL DEFAULT FALSE, applied_at TIMESTAMP)")
+			assert.NoError(t, err)
+			_, err = db.Exec("INSERT INTO migrations (migration_name, migration_hash, is_applied) VALUES ('001_test.sql', 'abc', TRUE)")
+			assert.NoError(t, err)
+
+			// Act
+			statuses, err := migrate.NewMigrator(db, noErrorsMigration).Status()
+
+			// Assert
+			assert.NoError(t, err)
+			assert.Len(t, statuses, 2)
+			assert.Equal(t, migrate.MigrationApplied, statuses[0].State)
+			assert.Zero(t, statuses[0].Duration)
+		})
+
+		t.Run("does not apply pending migrations", func(t *testing.T) {
+			// Arrange
+			var (
+				db   = migrate.SetupTestDatabase(t)
+				repo = test_data.NewRepo(db)
+			)
+
+			// Act
+			statuses, err := migrate.NewMigrator(db, noErrorsMigration).Status()
+
+			// Assert
+			assert.NoError(t, err)
+			assert.Len(t, statuses, 2)
+			migrations, err := repo.GetAllMigrations()
+			assert.NoError(t, err)
+			assert.Empty(t, migrations)
+		})
+	})
+}
+
+func TestApplied(t *testing.T) {
+	t.Run("Applied", func(t *testing.T) {
+		t.Run("returns the recorded migrations", func(t *testing.T) {
+			// Arrange
+			var (
+				db = migrate.SetupTestDatabase(t)
+			)
+			err := migrate.NewMigrator(db, noErrorsMigration).MigrateTo("1")
+			assert.NoError(t, err)
+
+			// Act
+			applied, err := migrate.NewMigrator(db, fstest.MapFS{}).Applied()
+
+			// Assert
+			assert.NoError(t, err)
+			assert.Len(t, applied, 1)
+			assert.Equal(t, "001_test.sql", applied[0].Name)
+			assert.True(t, applied[0].Applied)
+			assert.False(t, applied[0].Dirty)
+			assert.NotEmpty(t, applied[0].Hash)
+			assert.False(t, applied[0].AppliedAt.IsZero())
+		})
+
+		t.Run("reports who applied the migrations", func(t *testing.T) {
+			// Arrange
+			var (
+				db = migrate.SetupTestDatabase(t)
+			)
+			err := migrate.NewMigrator(db, noErrorsMigration, migrate.WithAppliedBy("ci-job-42")).MigrateTo("1")
+			assert.NoError(t, err)
+			err = migrate.NewMigrator(db, noErrorsMigration).Migrate()
+			assert.NoError(t, err)
+
+			// Act
+			applied, err := migrate.NewMigrator(db, fstest.MapFS{}).Applied()
+
+			// Assert
+			assert.NoError(t, err)
+			assert.Len(t, applied, 2)
+			assert.Equal(t, "ci-job-42", applied[0].AppliedBy)
+			assert.Empty(t, applied[1].AppliedBy)
+		})
+
+		t.Run("replaces who applied a migration that is applied again", func(t *testing.T) {
+			// Arrange
+			var (
+				db         = migrate.SetupTestDatabase(t)
+				migrations = fstest.MapFS{
+					"001_test.sql":      {Data: []byte("CREATE TABLE test (id INT PRIMARY KEY);")},
+					"001_test.down.sql": {Data: []byte("DROP TABLE test;")},
+				}
+			)
+			err := migrate.NewMigrator(db, migrations, migrate.WithAppliedBy("ci-job-42")).Migrate()
+			assert.NoError(t, err)
+			err = migrate.NewMigrator(db, migrations).Rollback(1)
+			assert.NoError(t, err)
+			err = migrate.NewMigrator(db, migrations).Migrate()
+			assert.NoError(t, err)
+
+			// Act
+			applied, err := migrate.NewMigrator(db, fstest.MapFS{}).Applied()
+
+			// Assert
+			assert.NoError(t, err)
+			assert.Len(t, applied, 1)
+			assert.True(t, applied[0].Applied)
+			assert.Empty(t, applied[0].AppliedBy)
+		})
+
+		t.Run("reports how long the migration took to execute", func(t *testing.T) {
+			// Arrange
+			var (
+				db       = migrate.SetupTestDatabase(t)
+				migrator = migrate.NewMigrator(db, fstest.MapFS{})
+			)
+			migrator.Register("001_slow", func(ctx context.Context, tx *sql.Tx) error {
+				time.Sleep(20 * time.Millisecond)
+				return nil
+			})
+			err := migrator.Migrate()
+			assert.NoError(t, err)
+
+			// Act
+			applied, err := migrate.NewMigrator(db, fstest.MapFS{}).Applied()
+
+			// Assert
+			assert.NoError(t, err)
+			assert.Len(t, applied, 1)
+			assert.GreaterOrEqual(t, applied[0].Duration, 20*time.Millisecond)
+		})
+
+		t.Run("reports nobody for a table without the applied by column", func(t *testing.T) {
+			// Arrange
+			var (
+				db = migrate.SetupTestDatabase(t)
+			)
+			_, err := db.Exec("CREATE TABLE migrations (migration_name VARCHAR(255) PRIMARY KEY, migration_hash VARCHAR(64), is_applied BOOLEAN NOT NULL DEFAULT FALSE, is_dirty BOOLEAN NOT NULL DEFAULT FALSE, applied_at TIMESTAMP)")
+			assert.NoError(t, err)
+			_, err = db.Exec("INSERT INTO migrations (migration_name, migration_hash, is_applied) VALUES ('001_test.sql', 'abc', TRUE)")
+			assert.NoError(t, err)
+
+			// Act
+			applied, err := migrate.NewMigrator(db, fstest.MapFS{}).Applied()
+
+			// Assert
+			assert.NoError(t, err)
+			assert.Len(t, applied, 1)
+			assert.Empty(t, applied[0].AppliedBy)
+		})
+
+		t.Run("returns the recorded migrations ordered by name", func(t *testing.T) {
+			// Arrange
+			var (
+				db     = migrate.SetupTestDatabase(t)
+				second = fstest.MapFS{
+					"002_second.sql": {Data: []byte("CREATE TABLE second (id INT PRIMARY KEY);")},
+				}
+				both = fstest.MapFS{
+					"001_first.sql":  {Data: []byte("CREATE TABLE first (id INT PRIMARY KEY);")},
+					"002_second.sql": second["002_second.sql"],
+				}
+			)
+			err := migrate.NewMigrator(db, second).Migrate()
+			assert.NoError(t, err)
+			err = migrate.NewMigrator(db, both).Migrate()
+			assert.NoError(t, err)
+
+			// Act
+			applied, err := migrate.NewMigrator(db, fstest.MapFS{}).Applied()
+
+			// Assert
+			assert.NoError(t, err)
+			assert.Len(t, applied, 2)
+			assert.Equal(t, "001_first.sql", applied[0].Name)
+			assert.Equal(t, "002_second.sql", applied[1].Name)
+		})
+
+		t.Run("records the time of the configured clock", func(t *testing.T) {
+			// Arrange
+			var (
+				db  = migrate.SetupTestDatabase(t)
+				now = time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC)
+			)
+			err := migrate.NewMigrator(db, noErrorsMigration, migrate.WithClock(func() time.Time { return now })).MigrateTo("1")
+			assert.NoError(t, err)
+
+			// Act
+			applied, err := migrate.NewMigrator(db, fstest.MapFS{}).Applied()
+
+			// Assert
+			assert.NoError(t, err)
+			assert.Len(t, applied, 1)
+			assert.True(t, now.Equal(applied[0].AppliedAt), "applied at %s", applied[0].AppliedAt)
+		})
+
+		t.Run("does not create the migrations table", func(t *testing.T) {
+			// Arrange
+			var (
+				db = migrate.SetupTestDatabase(t)
+			)
+
+			// Act
+			_, err := migrate.NewMigrator(db, noErrorsMigration).Applied()
+
+			// Assert
+			assert.Error(t, err)
+			_, err = db.Exec("SELECT migration_name FROM migrations")
+			assert.Error(t, err)
+		})
+	})
+}
+
+func TestVerify(t *testing.T) {
+	t.Run("Verify", func(t *testing.T) {
+		t.Run("succeeds when applied migrations match their files", func(t *testing.T) {
+			// Arrange
+			var (
+				db = migrate.SetupTestDatabase(t)
+			)
+			err := migrate.NewMigrator(db, noErrorsMigration).Migrate()
+			assert.NoError(t, err)
+
+			// Act
+			err = migrate.NewMigrator(db, noErrorsMigration).Verify()
+
+			// Assert
+			assert.NoError(t, err)
+		})
+
+		t.Run("reports altered and missing migrations", func(t *testing.T) {
+			// Arrange
+			var (
+				db         = migrate.SetupTestDatabase(t)
+				migrations = fstest.MapFS{
+					"001_test.sql":      {Data: []byte("CREATE TABLE test (id INT PRIMARY KEY);")},
+					"002_more_test.sql": {Data: []byte("CREATE TABLE more_test (id INT PRIMARY KEY);")},
+				}
+			)
+			err := migrate.NewMigrator(db, migrations).Migrate()
+			assert.NoError(t, err)
+			migrations["001_test.sql"] = &fstest.MapFile{Data: []byte("CREATE TABLE test (id BIGINT PRIMARY KEY);")}
+			delete(migrations, "002_more_test.sql")
+
+			// Act
+			err = migrate.NewMigrator(db, migrations).Verify()
+
+			// Assert
+			assert.ErrorIs(t, err, migrate.ErrMigrationFileChanged)
+			assert.ErrorIs(t, err, migrate.ErrMigrationFileMissing)
+			assert.ErrorContains(t, err, `migration "001_test.sql" has been altered`)
+			assert.ErrorContains(t, err, `migration "002_more_test.sql": migration file is missing`)
+		})
+
+		t.Run("ignores the migrations of other filters", func(t *testing.T) {
+			// Arrange
+			var (
+				db         = migrate.SetupTestDatabase(t)
+				migrations = fstest.MapFS{
+					"billing/001_invoices.sql": {Data: []byte("CREATE TABLE invoices (id INT PRIMARY KEY);")},
+					"users/002_users.sql":      {Data: []byte("CREATE TABLE users (id INT PRIMARY KEY);")},
+				}
+			)
+			err := migrate.NewMigrator(db, migrations, migrate.WithFilter("users/*.sql")).Migrate()
+			assert.NoError(t, err)
+
+			// Act
+			err = migrate.NewMigrator(db, migrations, migrate.WithFilter("billing/*.sql")).Verify()
+
+			// Assert
+			assert.NoError(t, err)
+		})
+
+		t.Run("reads the migrations table in a read-only transaction", func(t *testing.T) {
+			if _, ok := testDialect().(migrate.PostgresDialect); !ok {
+				t.Skip("test database does not enforce read-only transactions")
+			}
+
+			// Arrange
+			var (
+				db = migrate.SetupTestDatabase(t)
+			)
+			err := migrate.NewMigrator(db, noErrorsMigration).Migrate()
+			assert.NoError(t, err)
+			_, err = db.Exec("CREATE TABLE read_only_probe (id SERIAL PRIMARY KEY)")
+			assert.NoError(t, err)
+
+			// Act
+			err = migrate.NewMigrator(db, noErrorsMigration, migrate.WithDialect(writingSelectDialect{})).Verify()
+
+			// Assert
+			assert.ErrorContains(t, err, "read-only transaction")
+		})
+	})
+}
+
+func TestDriftReport(t *testing.T) {
+	t.Run("DriftReport", func(t *testing.T) {
+		t.Run("categorizes every migration", func(t *testing.T) {
+			// Arrange
+			var (
+				db         = migrate.SetupTestDatabase(t)
+				migrations = fstest.MapFS{
+					"001_test.sql":      {Data: []byte("CREATE TABLE test (id INT PRIMARY KEY);")},
+					"002_more_test.sql": {Data: []byte("CREATE TABLE more_test (id INT PRIMARY KEY);")},
+					"003_gone.sql":      {Data: []byte("CREATE TABLE gone (id INT PRIMARY KEY);")},
+				}
+			)
+			err := migrate.NewMigrator(db, migrations).Migrate()
+			assert.NoError(t, err)
+			migrations["002_more_test.sql"] = &fstest.MapFile{Data: []byte("CREATE TABLE more_test (id BIGINT PRIMARY KEY);")}
+			delete(migrations, "003_gone.sql")
+			migrations["004_new.sql"] = &fstest.MapFile{Data: []byte("CREATE TABLE new (id INT PRIMARY KEY);")}
+
+			// Act
+			report, err := migrate.NewMigrator(db, migrations).DriftReport()
+
+			// Assert
+			assert.NoError(t, err)
+			assert.True(t, report.Drifted())
+			assert.Len(t, report.Matching, 1)
+			assert.Equal(t, "001_test.sql", report.Matching[0].Name)
+			assert.Len(t, report.Altered, 1)
+			assert.Equal(t, "002_more_test.sql", report.Altered[0].Name)
+			assert.NotEqual(t, report.Altered[0].StoredHash, report.Altered[0].CurrentHash)
+			assert.Len(t, report.Pending, 1)
+			assert.Equal(t, "004_new.sql", report.Pending[0].Name)
+			assert.Len(t, report.Orphaned, 1)
+			assert.Equal(t, "003_gone.sql", report.Orphaned[0].Name)
+		})
+
+		t.Run("serializes empty categories as empty lists", func(t *testing.T) {
+			// Arrange
+			var (
+				db = migrate.SetupTestDatabase(t)
+			)
+			err := migrate.NewMigrator(db, noErrorsMigration).Migrate()
+			assert.NoError(t, err)
+
+			// Act
+			report, err := migrate.NewMigrator(db, noErrorsMigration).DriftReport()
+
+			// Assert
+			assert.NoError(t, err)
+			assert.False(t, report.Drifted())
+			encoded, err := json.Marshal(report)
+			assert.NoError(t, err)
+			assert.Contains(t, string(encoded), `"altered":[],"pending":[],"orphaned":[]`)
+		})
+	})
+}
+
+func TestPrecheck(t *testing.T) {
+	t.Run("Precheck", func(t *testing.T) {
+		t.Run("executes pending migrations and rolls them back", func(t *testing.T) {
+			// Arrange
+			var (
+				db   = migrate.SetupTestDatabase(t)
+				repo = test_data.NewRepo(db)
+			)
+
+			// Act
+			err := migrate.NewMigrator(db, noErrorsMigration).Precheck()
+
+			// Assert
+			assert.NoError(t, err)
+			rows, err := repo.GetAllMigrations()
+			assert.NoError(t, err)
+			assert.Empty(t, rows)
+			_, err = db.Exec("SELECT * FROM test")
+			assert.Error(t, err)
+			err = migrate.NewMigrator(db, noErrorsMigration).Migrate()
+			assert.NoError(t, err)
+		})
+
+		t.Run("should error without touching the database when DDL cannot be rolled back", func(t *testing.T) {
+			// Arrange
+			var (
+				db      = migrate.SetupTestDatabase(t)
+				repo    = test_data.NewRepo(db)
+				dialect = nonTransactionalDDLDialect{Dialect: testDialect()}
+			)
+
+			// Act
+			err := migrate.NewMigrator(db, noErrorsMigration, migrate.WithDialect(dialect)).Precheck()
+
+			// Assert
+			assert.ErrorContains(t, err, "cannot roll back DDL")
+			_, err = repo.GetAllMigrations()
+			assert.Error(t, err)
+			_, err = db.Exec("SELECT * FROM test")
+			assert.Error(t, err)
+		})
+
+		t.Run("reports every failing migration", func(t *testing.T) {
+			// Arrange
+			var (
+				db         = migrate.SetupTestDatabase(t)
+				repo       = test_data.NewRepo(db)
+				migrations = fstest.MapFS{
+					"001_test.sql":   {Data: []byte("CREATE TABLE test (id INT PRIMARY KEY);")},
+					"002_broken.sql": {Data: []byte("INSERT INTO test (missing) VALUES (1);")},
+					"003_more.sql":   {Data: []byte("INSERT INTO test (id) VALUES (1);")},
+					"004_broken.sql": {Data: []byte("THIS IS NOT SQL;")},
+				}
+			)
+
+			// Act
+			err := migrate.NewMigrator(db, migrations).Precheck()
+
+			// Assert
+			assert.ErrorIs(t, err, migrate.ErrMigrationFailed)
+			assert.ErrorContains(t, err, "002_broken.sql")
+			assert.ErrorContains(t, err, "004_broken.sql")
+			assert.NotContains(t, err.Error(), "003_more.sql")
+			rows, err := repo.GetAllMigrations()
+			assert.NoError(t, err)
+			assert.Empty(t, rows)
+		})
+	})
+}
+
+func TestDryRun(t *testing.T) {
+	t.Run("DryRunContext", func(t *testing.T) {
+		t.Run("should error when context is cancelled", func(t *testing.T) {
+			// Arrange
+			var (
+				db          = migrate.SetupTestDatabase(t)
+				ctx, cancel = context.WithCancel(context.Background())
+			)
+			cancel()
+
+			// Act
+			_, err := migrate.NewMigrator(db, noErrorsMigration).DryRunContext(ctx)
+
+			// Assert
+			assert.ErrorIs(t, err, context.Canceled)
+		})
+	})
+
+	t.Run("DryRun", func(t *testing.T) {
+		t.Run("returns sql of pending migrations without executing it", func(t *testing.T) {
+			// Arrange
+			var (
+				db      = migrate.SetupTestDatabase(t)
+				repo    = test_data.NewRepo(db)
+				applied = fstest.MapFS{
+					"001_test.sql": {Data: []byte("CREATE TABLE IF NOT EXISTS test (id INT PRIMARY KEY);")},
+				}
+				migrations = fstest.MapFS{
+					"001_test.sql": applied["001_test.sql"],
+					"002_new.sql":  {Data: []byte("CREATE TABLE new (id INT PRIMARY KEY);")},
+				}
+			)
+			err := migrate.NewMigrator(db, applied).Migrate()
+			assert.NoError(t, err)
+
+			// Act
+			statements, err := migrate.NewMigrator(db, migrations).DryRun()
+
+			// Assert
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"CREATE TABLE new (id INT PRIMARY KEY);"}, statements)
+			rows, err := repo.GetAllMigrations()
+			assert.NoError(t, err)
+			assert.Len(t, rows, 1)
+		})
+
+		t.Run("should error when migration file changed", func(t *testing.T) {
+			// Arrange
+			var (
+				db = migrate.SetupTestDatabase(t)
+			)
+			err := migrate.NewMigrator(db, changingMigrations).Migrate()
+			assert.NoError(t, err)
+
+			// Act
+			_, err = migrate.NewMigrator(db, changingMigrationsChanged).DryRun()
+
+			// Assert
+			assert.ErrorIs(t, err, migrate.ErrMigrationFileChanged)
+		})
+
+		t.Run("should error like Migrate when there are too many migrations", func(t *testing.T) {
+			// Arrange
+			var db = migrate.SetupTestDatabase(t)
+
+			// Act
+			_, err := migrate.NewMigrator(db, noErrorsMigration, migrate.WithMaxMigrations(1)).DryRun()
+
+			// Assert
+			assert.ErrorIs(t, err, migrate.ErrTooManyMigrations)
+		})
+
+		t.Run("should error like Migrate when an applied migration is missing", func(t *testing.T) {
+			// Arrange
+			var db = migrate.SetupTestDatabase(t)
+			err := migrate.NewMigrator(db, noErrorsMigration).Migrate()
+			assert.NoError(t, err)
+
+			// Act
+			_, err = migrate.NewMigrator(db, fstest.MapFS{}, migrate.WithOnMissing(migrate.MissingFail)).DryRun()
+
+			// Assert
+			assert.ErrorIs(t, err, migrate.ErrMigrationFileMissing)
+		})
+
+		t.Run("should error like Migrate when the lock file does not match", func(t *testing.T) {
+			// Arrange
+			var (
+				db   = migrate.SetupTestDatabase(t)
+				lock = fstest.MapFS{migrate.LockFileName: {Data: []byte("abc  001_test.sql\n")}}
+			)
+
+			// Act
+			_, err := migrate.NewMigrator(db, noErrorsMigration, migrate.WithLockFile(lock)).DryRun()
+
+			// Assert
+			assert.ErrorIs(t, err, migrate.ErrLockFileMismatch)
+		})
+	})
+}
+
+func TestExportGraph(t *testing.T) {
+	t.Run("writes migrations as a chain", func(t *testing.T) {
+		// Arrange
+		var (
+			sut   = migrate.NewMigrator(nil, noErrorsMigration)
+			graph strings.Builder
+		)
+
+		// Act
+		err := sut.ExportGraph(&graph)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, `digraph migrations {
+	rankdir=LR;
+	"001_test.sql" [label="1"];
+	"002_more_test.sql" [label="2"];
+	"001_test.sql" -> "002_more_test.sql";
+}
+`, graph.String())
+	})
+
+	t.Run("labels migrations with their version and description", func(t *testing.T) {
+		// Arrange
+		var (
+			migrations = fstest.MapFS{
+				"001_users.sql":  {Data: []byte("-- migrate:description Add the \"users\" table\nCREATE TABLE users (id INT PRIMARY KEY);")},
+				"010_orders.sql": {Data: []byte("CREATE TABLE orders (id INT PRIMARY KEY);")},
+			}
+			sut   = migrate.NewMigrator(nil, migrations)
+			graph strings.Builder
+		)
+
+		// Act
+		err := sut.ExportGraph(&graph)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Contains(t, graph.String(), `"001_users.sql" [label="1: Add the \"users\" table"];`)
+		assert.Contains(t, graph.String(), `"010_orders.sql" [label="10"];`)
 	})
 }
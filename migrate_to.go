@@ -0,0 +1,78 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+)
+
+// MigrateTo applies the pending migrations up to and including target and
+// leaves later migrations pending, even if they were never applied. target is
+// either the file name of a migration, its name after the name transformer or
+// its numeric version, e.g. "002_add_users.sql" or "2". It fails with
+// ErrMigrationTargetNotFound before touching the database if no migration
+// matches target.
+func (m *Migrator) MigrateTo(target string) error {
+	timeoutCtx, cancel := context.WithTimeout(context.Background(), m.options.migrationTimeout)
+	defer cancel()
+
+	return m.MigrateToContext(timeoutCtx, target)
+}
+
+// MigrateToContext is like MigrateTo but uses ctx for every database call
+// instead of the configured migration timeout.
+func (m *Migrator) MigrateToContext(ctx context.Context, target string) error {
+	version, err := m.targetVersion(target)
+	if err != nil {
+		return err
+	}
+
+	_, err = m.migrate(ctx, version)
+	return err
+}
+
+// targetVersion returns the version of the migration matching target.
+func (m *Migrator) targetVersion(target string) (int64, error) {
+	paths, err := m.migrationFiles()
+	if err != nil {
+		return 0, err
+	}
+
+	_, version, err := m.targetPath(paths, target)
+	return version, err
+}
+
+// targetPath returns the path and version of the migration in paths matching
+// target.
+func (m *Migrator) targetPath(paths []string, target string) (string, int64, error) {
+	numericTarget, err := strconv.ParseInt(target, 10, 64)
+	isNumeric := err == nil
+
+	for _, migrationPath := range paths {
+		fileName := m.migrationFileName(migrationPath)
+		version, err := parseVersion(fileName)
+		if err != nil {
+			return "", 0, err
+		}
+
+		if fileName == target || m.options.nameTransformer(fileName) == target || (isNumeric && version == numericTarget) {
+			return migrationPath, version, nil
+		}
+	}
+
+	return "", 0, fmt.Errorf("%q: %w", target, ErrMigrationTargetNotFound)
+}
+
+func (m *Migrator) pendingUpTo(pending []pendingMigration, maxVersion int64) ([]pendingMigration, error) {
+	for i, migration := range pending {
+		version, err := parseVersion(m.migrationFileName(migration.path))
+		if err != nil {
+			return nil, err
+		}
+		if version > maxVersion {
+			return pending[:i], nil
+		}
+	}
+
+	return pending, nil
+}
@@ -0,0 +1,48 @@
+package migrate
+
+import (
+	"fmt"
+)
+
+// MigrationPhase is the step during which a MigrationError occurred.
+type MigrationPhase string
+
+const (
+	// PhaseCheck is the check of an applied migration against its file.
+	PhaseCheck MigrationPhase = "check"
+	// PhaseExecute is the execution of a pending migration.
+	PhaseExecute MigrationPhase = "execute"
+	// PhaseRollback is the execution of a down migration.
+	PhaseRollback MigrationPhase = "rollback"
+)
+
+// MigrationError reports a single migration that failed, so callers can
+// extract it with errors.As to build a report. It wraps ErrMigrationFailed if
+// the migration failed to execute, and ErrMigrationFileChanged if its file was
+// altered.
+type MigrationError struct {
+	// Migration is the name the migration is recorded under.
+	Migration string
+	// File is the name of the file that was executed, or empty in PhaseCheck.
+	File string
+	// Phase is the step that failed.
+	Phase MigrationPhase
+	// Err is the underlying error, such as the error returned by the driver.
+	Err error
+}
+
+func (e *MigrationError) Error() string {
+	if e.Phase == PhaseCheck {
+		return fmt.Sprintf("migration %q has been altered, %v", e.Migration, e.Err)
+	}
+
+	return fmt.Sprintf("execute migration %q: %v: %v", e.File, e.Err, ErrMigrationFailed)
+}
+
+func (e *MigrationError) Unwrap() []error {
+	if e.Phase == PhaseCheck {
+		return []error{e.Err}
+	}
+
+	return []error{e.Err, ErrMigrationFailed}
+}
@@ -1,7 +1,24 @@
-CREATE TABLE IF NOT EXISTS migrations (
-    migration_name  VARCHAR(255) NOT NULL,
-    migration_hash  VARCHAR(64),
-    is_applied      BOOLEAN NOT NULL DEFAULT FALSE,
-    is_dirty        BOOLEAN NOT NULL DEFAULT FALSE,
-    primary key (migration_name)
-)
+-- Tables created before applied_at was tracked are upgraded in place. Hashes
+-- used to be limited to the 64 characters of a hex SHA-256 digest. Adding a
+-- column or widening VARCHAR to TEXT does not rewrite the table, but either
+-- still takes an exclusive lock, even when the column turns out to exist
+-- already, so they only run for tables that need them.
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1 FROM pg_attribute
+        WHERE attrelid = '%[1]s'::regclass AND attname = 'applied_at'
+          AND NOT attisdropped
+    ) THEN
+        ALTER TABLE %[1]s ADD COLUMN applied_at TIMESTAMP;
+    END IF;
+
+    IF EXISTS (
+        SELECT 1 FROM pg_attribute
+        WHERE attrelid = '%[1]s'::regclass AND attname = 'migration_hash'
+          AND atttypid <> 'text'::regtype AND NOT attisdropped
+    ) THEN
+        ALTER TABLE %[1]s ALTER COLUMN migration_hash TYPE TEXT;
+    END IF;
+END
+$$;
@@ -0,0 +1,86 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+)
+
+// MissingPolicy controls what Migrate does when an applied migration has no
+// file anymore, e.g. after it was deleted by accident or a branch without it
+// was checked out.
+type MissingPolicy int
+
+const (
+	// MissingWarn logs a warning for every missing migration and continues.
+	MissingWarn MissingPolicy = iota
+	// MissingFail fails with ErrMigrationFileMissing before applying anything.
+	MissingFail
+)
+
+// checkMissing handles applied migrations without a file according to the
+// configured MissingPolicy.
+func (m *Migrator) checkMissing(ctx context.Context, paths []string, knownMigrations migrationRows) error {
+	excluded, err := m.excludedMigrations()
+	if err != nil {
+		return err
+	}
+
+	onDisk := make(map[string]bool, len(paths))
+	for _, migrationPath := range paths {
+		migration, ok := m.findMigration(knownMigrations, migrationPath)
+		if ok {
+			onDisk[migration.MigrationName] = true
+		}
+	}
+
+	var errs []error
+	for _, migration := range knownMigrations.rows {
+		if !migration.IsApplied || onDisk[migration.MigrationName] || excluded[migration.MigrationName] {
+			continue
+		}
+
+		if m.options.onMissing == MissingFail {
+			errs = append(errs, fmt.Errorf("migration %q: %w", migration.MigrationName, ErrMigrationFileMissing))
+			continue
+		}
+		m.options.logger.WarnContext(ctx, "applied migration has no file", "migration", migration.MigrationName)
+	}
+
+	return errors.Join(errs...)
+}
+
+// excludedMigrations returns the recorded names of the migration files that
+// WithFilter excludes. Their rows belong to the migrators of other services
+// sharing the migrations table, so their files are not missing.
+func (m *Migrator) excludedMigrations() (map[string]bool, error) {
+	if m.options.filter == "" {
+		return nil, nil
+	}
+
+	excluded := map[string]bool{}
+	err := fs.WalkDir(m.migrations, ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return fmt.Errorf("walk func errored: %w", err)
+		}
+
+		if !m.isUpMigration(path, d) {
+			return nil
+		}
+		matched, err := m.matchesFilter(path)
+		if err != nil {
+			return err
+		}
+		if !matched {
+			excluded[m.migrationName(path)] = true
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return excluded, nil
+}
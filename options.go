@@ -1,9 +1,113 @@
 package migrate
 
-import "time"
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"time"
+)
 
 type options struct {
-	migrationTimeout time.Duration
+	migrationTimeout     time.Duration
+	perMigrationTimeout  time.Duration
+	nameTransformer      func(filename string) string
+	afterCommit          func(ctx context.Context, appliedMigrations []string) error
+	onBootstrap          func(table string)
+	postMigrationCheck   func(ctx context.Context, tx *sql.Tx) error
+	beforeEach           func(name string)
+	confirm              func(name string) (bool, error)
+	afterEach            func(name string, err error, duration time.Duration)
+	metrics              Metrics
+	dataFS               fs.FS
+	additionalFS         []fs.FS
+	versionConflict      VersionConflictPolicy
+	lockFile             fs.FS
+	parallelism          int
+	tableName            string
+	dialect              Dialect
+	lockMode             LockMode
+	lockTimeout          time.Duration
+	transactionMode      TransactionMode
+	isolationLevel       sql.IsolationLevel
+	hasher               func(content []byte) string
+	logger               *slog.Logger
+	layout               Layout
+	keyByPath            bool
+	identityByHash       bool
+	onAltered            AlteredPolicy
+	skipHashCheck        bool
+	statementSplitter    func(sql string) []string
+	outOfOrder           OutOfOrderPolicy
+	onMissing            MissingPolicy
+	filter               string
+	extension            string
+	clock                func() time.Time
+	appliedBy            string
+	errorOnEmpty         bool
+	maxMigrations        int
+	connectAttempts      int
+	savepoints           bool
+	keepSucceeded        bool
+	connectBackoff       time.Duration
+	schema               string
+	tableSchema          string
+	tableDDL             string
+	env                  string
+	normalizeLineEndings bool
+	normalizeContent     bool
+	allowReset           bool
+}
+
+func (o *options) validate() error {
+	if !isIdentifier(o.tableName) {
+		return fmt.Errorf("invalid table name %q: must be a plain SQL identifier of at most %d characters", o.tableName, maxIdentifierLength)
+	}
+	if o.schema != "" && !isIdentifier(o.schema) {
+		return fmt.Errorf("invalid schema %q: must be a plain SQL identifier of at most %d characters", o.schema, maxIdentifierLength)
+	}
+	if o.tableSchema != "" && !isIdentifier(o.tableSchema) {
+		return fmt.Errorf("invalid table schema %q: must be a plain SQL identifier of at most %d characters", o.tableSchema, maxIdentifierLength)
+	}
+	if o.tableDDL != "" {
+		err := validateTableDDL(o.tableDDL)
+		if err != nil {
+			return err
+		}
+	}
+	if o.connectAttempts < 0 {
+		return fmt.Errorf("invalid connect attempts %d: must not be negative", o.connectAttempts)
+	}
+	if o.connectBackoff < 0 {
+		return fmt.Errorf("invalid connect backoff %s: must not be negative", o.connectBackoff)
+	}
+
+	return nil
+}
+
+// validateStrict additionally rejects settings that only fail once a
+// migration runs. A zero migration timeout is tolerated by validate, as the
+// Context variants never use it.
+func (o *options) validateStrict() error {
+	err := o.validate()
+	if err != nil {
+		return err
+	}
+	if o.migrationTimeout <= 0 {
+		return fmt.Errorf("invalid migration timeout %s: must be positive", o.migrationTimeout)
+	}
+	if o.perMigrationTimeout < 0 {
+		return fmt.Errorf("invalid per-migration timeout %s: must not be negative", o.perMigrationTimeout)
+	}
+	if o.parallelism < 1 {
+		return fmt.Errorf("invalid parallelism %d: must be at least 1", o.parallelism)
+	}
+	if o.maxMigrations < 0 {
+		return fmt.Errorf("invalid maximum of migrations %d: must not be negative", o.maxMigrations)
+	}
+
+	return nil
 }
 
 func WithMigrationTimeout(timeout time.Duration) func(*options) {
@@ -11,3 +115,462 @@ func WithMigrationTimeout(timeout time.Duration) func(*options) {
 		opts.migrationTimeout = timeout
 	}
 }
+
+// WithPerMigrationTimeout bounds how long the statements of a single
+// migration may run, so one slow migration fails with an error naming it
+// instead of using up the whole migration timeout, which still bounds the run.
+func WithPerMigrationTimeout(timeout time.Duration) func(*options) {
+	return func(opts *options) {
+		opts.perMigrationTimeout = timeout
+	}
+}
+
+// WithNameTransformer sets a function that maps a migration's filename to the
+// name recorded in the migrations table, e.g. to strip a numeric prefix.
+// Migrations are still applied in the order of their original filenames.
+func WithNameTransformer(transform func(filename string) string) func(*options) {
+	return func(opts *options) {
+		opts.nameTransformer = transform
+	}
+}
+
+// WithAfterCommit sets a hook that is called once Migrate has applied every
+// pending migration successfully, with the names of the migrations applied
+// during that run. It is not called if any migration fails. An error from the
+// hook is returned by Migrate, but the migrations stay applied.
+func WithAfterCommit(hook func(ctx context.Context, appliedMigrations []string) error) func(*options) {
+	return func(opts *options) {
+		opts.afterCommit = hook
+	}
+}
+
+// WithPostMigrationCheck sets a check that runs once a run has applied its
+// migrations, e.g. to assert invariants of the resulting schema. With
+// AllInOne and MigrateTx it runs in the transaction of the migrations before
+// it is committed, so a failing check rolls back the whole run. With the
+// other transaction modes the migrations are committed already, so it runs in
+// a transaction of its own and a failure is only reported. It is not called
+// when nothing was pending.
+func WithPostMigrationCheck(check func(ctx context.Context, tx *sql.Tx) error) func(*options) {
+	return func(opts *options) {
+		opts.postMigrationCheck = check
+	}
+}
+
+// WithOnBootstrap sets a hook that is called with the name of the migrations
+// table when the Migrator creates it, telling a fresh database from an
+// established one. Creating the table is also logged. Whether the table
+// exists is only checked with a hook set, which costs one catalog query.
+func WithOnBootstrap(hook func(table string)) func(*options) {
+	return func(opts *options) {
+		opts.onBootstrap = hook
+	}
+}
+
+// WithConfirm sets a callback that must approve every pending migration with
+// a "-- migrate:destructive" directive, e.g. by asking an operator. It is
+// called with the migration's name before any migration of the run is
+// applied. Returning false aborts the run with ErrMigrationNotConfirmed.
+// Without it, destructive migrations run like any other.
+func WithConfirm(confirm func(name string) (bool, error)) func(*options) {
+	return func(opts *options) {
+		opts.confirm = confirm
+	}
+}
+
+// WithBeforeEach sets a hook that is called right before each migration is
+// applied, with the migration's name. A panicking hook fails the migration
+// before it runs. With a parallelism above one, the hook must be safe for
+// concurrent use.
+func WithBeforeEach(hook func(name string)) func(*options) {
+	return func(opts *options) {
+		opts.beforeEach = hook
+	}
+}
+
+// WithAfterEach sets a hook that is called after each migration, with the
+// migration's name, the error it failed with, if any, and how long it took.
+// A panicking hook fails the migration. With a parallelism above one, the
+// hook must be safe for concurrent use.
+func WithAfterEach(hook func(name string, err error, duration time.Duration)) func(*options) {
+	return func(opts *options) {
+		opts.afterEach = hook
+	}
+}
+
+// WithMetrics sets the Metrics that every applied and every failed migration
+// is reported to, after the after each hook.
+func WithMetrics(metrics Metrics) func(*options) {
+	return func(opts *options) {
+		opts.metrics = metrics
+	}
+}
+
+// WithDataFS sets the filesystem that data files referenced by
+// "-- migrate:data-file" directives are read from. Keeping large seed data
+// out of the migrations filesystem lets it live outside the binary.
+func WithDataFS(fsys fs.FS) func(*options) {
+	return func(opts *options) {
+		opts.dataFS = fsys
+	}
+}
+
+// WithAdditionalFS adds a filesystem of migrations to the one passed to
+// NewMigrator, e.g. migrations shipped by a library. The migrations of all
+// filesystems are ordered by version together, so versions must be unique
+// across them unless WithVersionConflictPolicy says otherwise. It can be used
+// several times.
+func WithAdditionalFS(fsys fs.FS) func(*options) {
+	return func(opts *options) {
+		opts.additionalFS = append(opts.additionalFS, fsys)
+	}
+}
+
+// WithVersionConflictPolicy sets which migration is used when several
+// filesystems provide one with the same version, or a file at the same path
+// with different content. The migration that is used is the one executed and
+// hashed.
+func WithVersionConflictPolicy(policy VersionConflictPolicy) func(*options) {
+	return func(opts *options) {
+		opts.versionConflict = policy
+	}
+}
+
+// WithParallelism sets how many migrations may be applied at once. Only
+// consecutive migrations marked with a "-- migrate:parallel-safe" directive are
+// applied concurrently, each on its own connection; all other migrations are
+// applied one at a time, in order.
+func WithParallelism(n int) func(*options) {
+	return func(opts *options) {
+		opts.parallelism = n
+	}
+}
+
+// WithTableName sets the name of the table that tracks applied migrations.
+// The name is interpolated into queries, so it must be a plain SQL identifier:
+// letters, digits and underscores, not starting with a digit.
+func WithTableName(name string) func(*options) {
+	return func(opts *options) {
+		opts.tableName = name
+	}
+}
+
+// WithDialect sets the Dialect used for the migrations table. Without it the
+// dialect is detected from the database driver.
+func WithDialect(dialect Dialect) func(*options) {
+	return func(opts *options) {
+		opts.dialect = dialect
+	}
+}
+
+// WithLockMode sets what Migrate and Rollback do when another process holds
+// the migration lock: wait for it with LockWait, or return ErrMigrationLocked
+// with LockFailFast.
+func WithLockMode(mode LockMode) func(*options) {
+	return func(opts *options) {
+		opts.lockMode = mode
+	}
+}
+
+// WithLockTimeout bounds how long LockWait waits for the migration lock
+// before returning ErrMigrationLocked, so a stuck lock does not use up the
+// whole migration timeout.
+func WithLockTimeout(timeout time.Duration) func(*options) {
+	return func(opts *options) {
+		opts.lockTimeout = timeout
+	}
+}
+
+// WithTransactionMode sets whether migrations run outside a transaction
+// (NoTransaction), each in its own transaction (PerMigration) or all in one
+// transaction (AllInOne).
+func WithTransactionMode(mode TransactionMode) func(*options) {
+	return func(opts *options) {
+		opts.transactionMode = mode
+	}
+}
+
+// WithIsolationLevel sets the isolation level of the transactions migrations
+// run in, such as sql.LevelSerializable, and of the read-only transactions of
+// Verify, Applied and DriftReport. Drivers fail to begin a transaction with a
+// level they do not support.
+func WithIsolationLevel(level sql.IsolationLevel) func(*options) {
+	return func(opts *options) {
+		opts.isolationLevel = level
+	}
+}
+
+// WithHasher sets the function that computes the hash stored for every
+// migration, e.g. to use SHA-512 or a faster non-cryptographic hash. Hashes
+// stored with another hasher no longer match, so switching hashers on an
+// existing database requires a Repair. Files are hashed concurrently, so
+// hasher must be safe for concurrent use.
+func WithHasher(hasher func(content []byte) string) func(*options) {
+	return func(opts *options) {
+		opts.hasher = hasher
+	}
+}
+
+// WithNormalizeLineEndings makes the stored hash independent of line endings
+// by stripping carriage returns from a migration before hashing it, so a file
+// checked out with CRLF line endings matches the same file with LF. The SQL is
+// still executed byte for byte.
+func WithNormalizeLineEndings(normalize bool) func(*options) {
+	return func(opts *options) {
+		opts.normalizeLineEndings = normalize
+	}
+}
+
+// WithNormalizeContent makes the stored hash independent of a leading UTF-8
+// byte order mark and of trailing whitespace, at the end of lines and of the
+// file, so a file an editor saved with a BOM or with trailing spaces trimmed
+// is not reported as altered. The SQL is still executed byte for byte.
+func WithNormalizeContent(normalize bool) func(*options) {
+	return func(opts *options) {
+		opts.normalizeContent = normalize
+	}
+}
+
+// WithLogger sets the logger that reports the progress of Migrate and
+// Rollback: every migration applied, with its duration, at info level,
+// failures at error level and skipped migrations at debug level.
+func WithLogger(logger *slog.Logger) func(*options) {
+	return func(opts *options) {
+		opts.logger = logger
+	}
+}
+
+// WithLayout sets how migrations are laid out in the migrations filesystem:
+// as flat ".sql" files (FlatLayout) or as directories holding an "up.sql"
+// and an optional "down.sql" (DirectoryLayout).
+func WithLayout(layout Layout) func(*options) {
+	return func(opts *options) {
+		opts.layout = layout
+	}
+}
+
+// WithIdentityByHash treats a migration as applied when an applied row has
+// the same hash, even if it is recorded under another name, so renaming or
+// moving a file without changing its content does not apply it again. The row
+// keeps the name it was applied under. A new file with exactly the content of
+// an applied migration is treated as applied too.
+func WithIdentityByHash() func(*options) {
+	return func(opts *options) {
+		opts.identityByHash = true
+	}
+}
+
+// WithKeyByPath records migrations by their path within the migrations
+// filesystem, e.g. "users/001_init.sql", instead of their file name, so the
+// directory is part of a migration's identity. The name transformer receives
+// the path. Migrations recorded by file name before are still recognized.
+func WithKeyByPath() func(*options) {
+	return func(opts *options) {
+		opts.keyByPath = true
+	}
+}
+
+// WithOnAltered sets what Migrate does when an applied migration's file has
+// been altered: fail with ErrMigrationFileChanged (AlteredFail), log a
+// warning and continue (AlteredWarn), or store the new hash and continue
+// (AlteredUpdate).
+func WithOnAltered(policy AlteredPolicy) func(*options) {
+	return func(opts *options) {
+		opts.onAltered = policy
+	}
+}
+
+// WithOutOfOrder sets what Migrate does with a pending migration whose version
+// is lower than that of an applied migration: apply it (OutOfOrderAllow), log
+// a warning and apply it (OutOfOrderWarn), or fail with
+// ErrOutOfOrderMigration (OutOfOrderStrict).
+func WithOutOfOrder(policy OutOfOrderPolicy) func(*options) {
+	return func(opts *options) {
+		opts.outOfOrder = policy
+	}
+}
+
+// WithOnMissing sets what Migrate does when an applied migration has no file
+// anymore: log a warning and continue (MissingWarn), or fail with
+// ErrMigrationFileMissing (MissingFail).
+func WithOnMissing(policy MissingPolicy) func(*options) {
+	return func(opts *options) {
+		opts.onMissing = policy
+	}
+}
+
+// WithFilter restricts the migrations to the files whose path in the
+// migrations filesystem matches glob, as matched by path.Match, e.g.
+// "billing/*.sql". Files that do not match are ignored entirely, so one
+// filesystem can serve several migrators. Registered Go migrations are not
+// filtered.
+func WithFilter(glob string) func(*options) {
+	return func(opts *options) {
+		opts.filter = glob
+	}
+}
+
+// WithExtension sets the extension of migration files in the flat layout,
+// e.g. ".pgsql". Other files, such as a README.md next to the migrations, are
+// ignored. An empty extension treats every file as a migration.
+func WithExtension(ext string) func(*options) {
+	return func(opts *options) {
+		opts.extension = ext
+	}
+}
+
+// WithClock sets the function that provides the time recorded as applied_at,
+// so tests can assert deterministic timestamps. Durations reported to hooks,
+// metrics and logs are still measured with the monotonic clock.
+func WithClock(now func() time.Time) func(*options) {
+	return func(opts *options) {
+		opts.clock = now
+	}
+}
+
+// WithAppliedBy records by, such as a CI job ID or the application version,
+// as applied_by of every migration Migrate applies, for audits that trace
+// schema changes to a deploy. Applied and Status report it.
+func WithAppliedBy(by string) func(*options) {
+	return func(opts *options) {
+		opts.appliedBy = by
+	}
+}
+
+// WithErrorOnEmpty makes Migrate fail with ErrNoMigrations when there are no
+// migrations at all, instead of only logging a warning.
+func WithErrorOnEmpty() func(*options) {
+	return func(opts *options) {
+		opts.errorOnEmpty = true
+	}
+}
+
+// WithMaxMigrations makes Migrate fail with ErrTooManyMigrations before
+// applying anything when there are more than n migrations. Zero means no
+// limit.
+func WithMaxMigrations(n int) func(*options) {
+	return func(opts *options) {
+		opts.maxMigrations = n
+	}
+}
+
+// WithConnectRetry makes acquiring a connection and creating the migrations
+// table try up to attempts times, waiting backoff, but at least 10ms, after
+// the first failure and twice as long after every further one, bounded by the
+// deadline of the run. It is meant for applications that boot alongside their
+// database. Only connection errors are retried, such as failing to dial the
+// database or a connection dropped while the table is created; errors of SQL
+// statements the database refused are never retried. Negative values are
+// reported once a migration runs.
+func WithConnectRetry(attempts int, backoff time.Duration) func(*options) {
+	return func(opts *options) {
+		opts.connectAttempts = attempts
+		opts.connectBackoff = backoff
+	}
+}
+
+// WithSavepoints runs every migration of an AllInOne run under a savepoint.
+// A failing migration is rolled back to its savepoint instead of aborting the
+// run, the remaining migrations are still executed and the successful ones
+// are committed, while Migrate reports the failed ones. It has no effect in
+// the other transaction modes.
+func WithSavepoints() func(*options) {
+	return func(opts *options) {
+		opts.savepoints = true
+	}
+}
+
+// WithKeepSucceeded, when keep is true, commits every migration on its own as
+// in PerMigration even if AllInOne is configured, so a failing migration
+// keeps the ones applied before it. MigrateResult then reports them together
+// with the error.
+func WithKeepSucceeded(keep bool) func(*options) {
+	return func(opts *options) {
+		opts.keepSucceeded = keep
+	}
+}
+
+// WithEnv sets the environment migrations are applied in. Migrations with a
+// "-- migrate:env" directive are only applied if it lists env; the others are
+// left unrecorded, so they are applied once the environment matches.
+// Migrations without the directive are applied in every environment.
+func WithEnv(env string) func(*options) {
+	return func(opts *options) {
+		opts.env = env
+	}
+}
+
+// WithSkipHashCheck makes Migrate apply unapplied migrations by name without
+// checking applied migrations for changes at all, for forward only deploys
+// that tolerate deliberately edited history. Hashes are still stored. Unlike
+// AlteredWarn, altered migrations are not even read, so nothing is logged.
+func WithSkipHashCheck() func(*options) {
+	return func(opts *options) {
+		opts.skipHashCheck = true
+	}
+}
+
+// WithStatementSplitter makes Migrate and Rollback execute the statements of a
+// migration one at a time, as returned by splitter, instead of sending the
+// whole file as one query, for drivers that only accept a single statement per
+// query. A failing statement is reported by its position. SplitStatements
+// splits at top level semicolons. The splitter takes precedence over a dialect
+// that is a BodyExecutor.
+func WithStatementSplitter(splitter func(sql string) []string) func(*options) {
+	return func(opts *options) {
+		opts.statementSplitter = splitter
+	}
+}
+
+// WithSchema makes every connection the Migrator uses work in schema, which
+// is created if it does not exist, so an application's tables, including the
+// migrations table, can live in a dedicated schema without changing the
+// connection string. On MySQL the schema is a database. Connections are
+// discarded instead of returned to the pool afterwards, so the schema does
+// not leak into the rest of the application.
+func WithSchema(name string) func(*options) {
+	return func(opts *options) {
+		opts.schema = name
+	}
+}
+
+// WithTableSchema keeps the migrations table in schema, which is created if
+// it does not exist, while the migrations themselves run in the schema of the
+// connection. Unlike WithSchema it only qualifies the table name, so the
+// bookkeeping survives dropping the application's schema. On MySQL the schema
+// is a database.
+func WithTableSchema(schema string) func(*options) {
+	return func(opts *options) {
+		opts.tableSchema = schema
+	}
+}
+
+// WithTableDDL replaces the statement that creates the migrations table, e.g.
+// to add indexes or columns of your own. It runs before every migration, so it
+// should only create the table if it does not exist yet, and it must create
+// the configured table with at least the columns the Migrator reads.
+func WithTableDDL(ddl string) func(*options) {
+	return func(opts *options) {
+		opts.tableDDL = ddl
+	}
+}
+
+// WithLockFile makes Migrate check every migration against the
+// migrations.lock manifest in fsys, as written by GenerateLock, before
+// touching the database. A migration whose hash differs from the manifest, or
+// that is missing from it or from the migrations, fails the run with
+// ErrLockFileMismatch.
+func WithLockFile(fsys fs.FS) func(*options) {
+	return func(opts *options) {
+		opts.lockFile = fsys
+	}
+}
+
+// WithAllowReset allows Reset, which forgets every migration. Only pass it
+// where wiping the migration state is intended, such as development tooling,
+// never in the code path that migrates production.
+func WithAllowReset() func(*options) {
+	return func(opts *options) {
+		opts.allowReset = true
+	}
+}
@@ -0,0 +1,51 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+)
+
+// OutOfOrderPolicy controls what Migrate does with a pending migration whose
+// version is lower than that of an applied migration, as happens when two
+// branches add migrations and the one with the lower version is merged last.
+type OutOfOrderPolicy int
+
+const (
+	// OutOfOrderAllow applies such migrations like any other.
+	OutOfOrderAllow OutOfOrderPolicy = iota
+	// OutOfOrderWarn logs a warning and applies them.
+	OutOfOrderWarn
+	// OutOfOrderStrict fails with ErrOutOfOrderMigration before applying
+	// anything.
+	OutOfOrderStrict
+)
+
+// checkOrder handles pending migrations older than the latest applied one
+// according to the configured OutOfOrderPolicy.
+func (m *Migrator) checkOrder(ctx context.Context, paths []string, knownMigrations migrationRows, pending []pendingMigration) error {
+	if m.options.outOfOrder == OutOfOrderAllow || len(pending) == 0 {
+		return nil
+	}
+
+	latest, err := m.result(paths, knownMigrations, nil)
+	if err != nil {
+		return err
+	}
+
+	for _, migration := range pending {
+		version, err := parseVersion(m.migrationFileName(migration.path))
+		if err != nil {
+			return err
+		}
+		if version >= latest.LatestVersion {
+			continue
+		}
+
+		if m.options.outOfOrder == OutOfOrderStrict {
+			return fmt.Errorf("migration %q has version %d, but version %d is already applied: %w", migration.name, version, latest.LatestVersion, ErrOutOfOrderMigration)
+		}
+		m.options.logger.WarnContext(ctx, "applying migration out of order", "migration", migration.name, "version", version, "latest_applied_version", latest.LatestVersion)
+	}
+
+	return nil
+}
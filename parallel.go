@@ -0,0 +1,93 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"sync/atomic"
+)
+
+// parallelSafeDirective marks a migration as independent of the migrations
+// around it, so it may be applied concurrently with them, e.g.
+//
+//	-- migrate:parallel-safe
+const parallelSafeDirective = "parallel-safe"
+
+func (p pendingMigration) isParallelSafe() bool {
+	return slices.ContainsFunc(p.directives, func(d directive) bool {
+		return d.name == parallelSafeDirective
+	})
+}
+
+// parallelism returns how many migrations may be applied at once. Workers take
+// their own connection from the pool while the run keeps holding one, so the
+// configured parallelism is capped to what the pool can hand out.
+func (m *Migrator) parallelism() int {
+	maxOpen := m.db.Stats().MaxOpenConnections
+	if maxOpen > 0 {
+		return min(m.options.parallelism, maxOpen-1)
+	}
+	return m.options.parallelism
+}
+
+// applyConcurrently applies migrations on separate connections, running at
+// most parallelism at once. Once a migration fails no further
+// migrations are started, but those already running are allowed to finish.
+// It returns the names of the applied migrations in their original order.
+func (m *Migrator) applyConcurrently(ctx context.Context, migrations []pendingMigration, parallelism int) ([]string, error) {
+	var (
+		wg      sync.WaitGroup
+		workers = make(chan struct{}, parallelism)
+		errs    = make([]error, len(migrations))
+		failed  atomic.Bool
+		started int
+	)
+
+	for i, migration := range migrations {
+		workers <- struct{}{}
+		if failed.Load() {
+			break
+		}
+
+		started++
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-workers }()
+
+			errs[i] = m.applyOnOwnConnection(ctx, migration)
+			if errs[i] != nil {
+				failed.Store(true)
+			}
+		}()
+	}
+	wg.Wait()
+
+	var appliedMigrations []string
+	for i, migration := range migrations[:started] {
+		if errs[i] == nil {
+			appliedMigrations = append(appliedMigrations, migration.name)
+		}
+	}
+
+	return appliedMigrations, errors.Join(errs...)
+}
+
+// applyOnOwnConnection applies a migration on a connection of its own, in a
+// transaction of its own whatever the transaction mode, so a migration that
+// fails halfway is rolled back while the others keep running. Only a
+// migration with the no-transaction directive runs outside one.
+func (m *Migrator) applyOnOwnConnection(ctx context.Context, migration pendingMigration) error {
+	conn, err := m.conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer m.closeConn(conn)
+
+	if migration.isNoTransaction() {
+		return m.executeMigration(conn, ctx, migration)
+	}
+
+	return m.applyMigrationInTransaction(conn, ctx, migration)
+}
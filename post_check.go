@@ -0,0 +1,48 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// postMigrationCheck runs the check set with WithPostMigrationCheck in tx.
+func (m *Migrator) postMigrationCheck(tx *sql.Tx, ctx context.Context) error {
+	if m.options.postMigrationCheck == nil {
+		return nil
+	}
+
+	err := m.options.postMigrationCheck(ctx, tx)
+	if err != nil {
+		return fmt.Errorf("post-migration check: %w", err)
+	}
+
+	return nil
+}
+
+// postMigrationCheckInTransaction runs the post-migration check in a
+// transaction of its own, for transaction modes that leave no transaction
+// open once the migrations are applied.
+func (m *Migrator) postMigrationCheckInTransaction(conn *sql.Conn, ctx context.Context) error {
+	if m.options.postMigrationCheck == nil {
+		return nil
+	}
+
+	tx, err := conn.BeginTx(ctx, m.txOptions())
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	err = m.postMigrationCheck(tx, ctx)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("commit post-migration check: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,76 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Precheck executes every pending migration in a transaction that is always
+// rolled back, to catch errors that only show once the SQL runs, such as a
+// reference to a missing column, before migrating the primary database. Each
+// migration runs under a savepoint, so a failing one does not hide the
+// failures of the ones after it; the returned error joins all of them.
+// Migrations with a "-- migrate:no-transaction" directive cannot be rolled
+// back and are skipped. Apart from ensuring the migrations table exists, it
+// leaves the database as it was. As that relies on rolling back DDL, it
+// refuses to run on a dialect that reports it cannot, such as MySQLDialect.
+func (m *Migrator) Precheck() error {
+	timeoutCtx, cancel := context.WithTimeout(context.Background(), m.options.migrationTimeout)
+	defer cancel()
+
+	return m.PrecheckContext(timeoutCtx)
+}
+
+// PrecheckContext is like Precheck but uses ctx for every database call
+// instead of the configured migration timeout.
+func (m *Migrator) PrecheckContext(ctx context.Context) error {
+	if !m.transactionalDDL() {
+		return fmt.Errorf("precheck: dialect %T cannot roll back DDL, so the migrations would be applied for real", m.dialect())
+	}
+
+	err := m.VerifyLock()
+	if err != nil {
+		return err
+	}
+
+	conn, release, err := m.connectLocked(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	tx, err := conn.BeginTx(ctx, m.txOptions())
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = m.migrateWith(tx, ctx, math.MaxInt64, func(pending []pendingMigration) ([]string, error) {
+		var errs []error
+		for _, migration := range pending {
+			if migration.isNoTransaction() {
+				m.options.logger.WarnContext(ctx, "skipped precheck of migration", "migration", migration.name, "directive", noTransactionDirective)
+				continue
+			}
+
+			// runMigration leaves out the hooks and metrics, as nothing is
+			// applied for real.
+			migrationErr, err := m.executeInSavepoint(tx, ctx, migration, m.runMigration)
+			if err != nil {
+				return nil, errors.Join(append(errs, err)...)
+			}
+			if migrationErr != nil {
+				errs = append(errs, migrationErr)
+			}
+		}
+
+		return nil, errors.Join(errs...)
+	})
+	if err != nil {
+		return fmt.Errorf("precheck: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,98 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+)
+
+// Repair stores the current hash of every applied migration whose file has
+// changed since it was applied, without executing any migration. Use it after
+// a legitimate edit of an applied migration, such as reformatting whitespace
+// or comments, that would otherwise make Migrate fail with
+// ErrMigrationFileChanged. Migrate never repairs hashes on its own.
+func (m *Migrator) Repair() error {
+	timeoutCtx, cancel := context.WithTimeout(context.Background(), m.options.migrationTimeout)
+	defer cancel()
+
+	return m.RepairContext(timeoutCtx)
+}
+
+// RepairContext is like Repair but uses ctx for every database call instead
+// of the configured migration timeout.
+func (m *Migrator) RepairContext(ctx context.Context) error {
+	conn, release, err := m.connectLocked(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	knownMigrations, err := m.getMigrationsKnownToDb(conn, ctx)
+	if err != nil {
+		return err
+	}
+	if hasDirtyMigration(knownMigrations) {
+		return ErrDirtyMigration
+	}
+
+	paths, err := m.migrationFiles()
+	if err != nil {
+		return err
+	}
+
+	for _, migrationPath := range paths {
+		migration, ok := m.findMigration(knownMigrations, migrationPath)
+		if !ok || !migration.IsApplied {
+			continue
+		}
+
+		readBytes, err := m.readMigration(migrationPath)
+		if err != nil {
+			return fmt.Errorf("read migration file %q: %w", m.migrationFileName(migrationPath), err)
+		}
+
+		migrationHash, err := m.hashMigration(readBytes)
+		if err != nil {
+			return fmt.Errorf("hash migration %q: %w", m.migrationFileName(migrationPath), err)
+		}
+		if migrationHash == migration.MigrationHash {
+			continue
+		}
+
+		err = m.updateMigrationHash(conn, ctx, migration.MigrationName, migrationHash)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// AlteredPolicy controls what Migrate does when the file of an applied
+// migration no longer matches its stored hash.
+type AlteredPolicy int
+
+const (
+	// AlteredFail fails with ErrMigrationFileChanged.
+	AlteredFail AlteredPolicy = iota
+	// AlteredWarn logs a warning and continues, keeping the stored hash.
+	AlteredWarn
+	// AlteredUpdate logs a warning, stores the current hash and continues,
+	// like Repair does for every altered migration.
+	AlteredUpdate
+)
+
+// updateMigrationHash only changes the stored hash, so applied_at keeps the
+// time the migration was actually applied.
+func (m *Migrator) updateMigrationHash(db execer, ctx context.Context, migrationName string, migrationHash string) error {
+	var (
+		dialect = m.dialect()
+		query   = fmt.Sprintf("UPDATE %s SET migration_hash = %s WHERE migration_name = %s", m.table(), dialect.Placeholder(1), dialect.Placeholder(2))
+	)
+
+	_, err := db.ExecContext(ctx, query, migrationHash, migrationName)
+	if err != nil {
+		return fmt.Errorf("update hash of migration %q: %w", migrationName, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,74 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"slices"
+)
+
+// Reset forgets every migration, so the next Migrate applies all of them from
+// scratch, e.g. to start over with a development database. Applied migrations
+// that have a ".down.sql" counterpart are rolled back first, newest first;
+// the objects created by migrations without one are left in place, so Reset
+// alone does not drop the application's tables. It then deletes every row of
+// the migrations table, including dirty ones.
+//
+// As it is destructive, Reset fails with ErrResetNotAllowed unless the
+// Migrator was created with WithAllowReset.
+func (m *Migrator) Reset() error {
+	timeoutCtx, cancel := context.WithTimeout(context.Background(), m.options.migrationTimeout)
+	defer cancel()
+
+	return m.ResetContext(timeoutCtx)
+}
+
+// ResetContext is like Reset but uses ctx for every database call instead of
+// the configured migration timeout.
+func (m *Migrator) ResetContext(ctx context.Context) error {
+	if !m.options.allowReset {
+		return ErrResetNotAllowed
+	}
+
+	conn, release, err := m.connectLocked(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	knownMigrations, err := m.getMigrationsKnownToDb(conn, ctx)
+	if err != nil {
+		return err
+	}
+
+	paths, err := m.migrationFiles()
+	if err != nil {
+		return err
+	}
+
+	for _, migrationPath := range slices.Backward(paths) {
+		migration, ok := m.findMigration(knownMigrations, migrationPath)
+		if !ok || !migration.IsApplied || migration.IsDirty {
+			continue
+		}
+
+		_, err := fs.Stat(m.migrations, m.downMigrationPath(migrationPath))
+		if err != nil {
+			m.options.logger.WarnContext(ctx, "reset keeps the objects of a migration without down migration", "migration", migration.MigrationName)
+			continue
+		}
+
+		err = m.rollbackMigration(conn, ctx, knownMigrations, migrationPath)
+		if err != nil {
+			return fmt.Errorf("reset: %w", err)
+		}
+	}
+
+	_, err = conn.ExecContext(ctx, fmt.Sprintf("DELETE FROM %s", m.table()))
+	if err != nil {
+		return fmt.Errorf("reset: clear migrations table: %w", err)
+	}
+
+	m.options.logger.WarnContext(ctx, "reset migrations", "table", m.table(), "count", len(knownMigrations.rows))
+	return nil
+}
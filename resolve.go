@@ -0,0 +1,59 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+)
+
+// ResolveDirty clears the dirty flag of the migration name, e.g. after a
+// process crashed while running it outside a transaction. The migration is
+// marked dirty before it runs, so Migrate refuses to run it a second time, but
+// whether its statements took effect is only known by inspecting the
+// database. Pass applied as true if they did, so Migrate skips it from now
+// on, or as false once its effects have been undone, so Migrate applies it
+// again.
+func (m *Migrator) ResolveDirty(name string, applied bool) error {
+	timeoutCtx, cancel := context.WithTimeout(context.Background(), m.options.migrationTimeout)
+	defer cancel()
+
+	return m.ResolveDirtyContext(timeoutCtx, name, applied)
+}
+
+// ResolveDirtyContext is like ResolveDirty but uses ctx for every database
+// call instead of the configured migration timeout.
+func (m *Migrator) ResolveDirtyContext(ctx context.Context, name string, applied bool) error {
+	conn, release, err := m.connectLocked(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	knownMigrations, err := m.getMigrationsKnownToDb(conn, ctx)
+	if err != nil {
+		return err
+	}
+
+	migration, ok := knownMigrations.find(name)
+	if !ok {
+		return fmt.Errorf("migration %q: %w", name, ErrMigrationTargetNotFound)
+	}
+	if !migration.IsDirty {
+		return fmt.Errorf("migration %q is not dirty", name)
+	}
+
+	// The stored hash was taken from the file right before it ran, so it is
+	// kept as the hash of what was applied.
+	migration.IsApplied = applied
+	migration.IsDirty = false
+	migration.AppliedBy = ""
+	if applied {
+		migration.AppliedBy = m.options.appliedBy
+	}
+	err = m.upsertMigration(conn, ctx, migration)
+	if err != nil {
+		return err
+	}
+
+	m.options.logger.InfoContext(ctx, "resolved dirty migration", "migration", name, "applied", applied)
+	return nil
+}
@@ -0,0 +1,59 @@
+package migrate
+
+import (
+	"context"
+	"math"
+	"slices"
+)
+
+// Result describes a run of MigrateResult.
+type Result struct {
+	// Count is the number of migrations applied during the run.
+	Count int
+	// Applied holds the names of the migrations applied during the run, in
+	// the order they were applied.
+	Applied []string
+	// LatestVersion is the highest version of all applied migrations after
+	// the run, including those applied before it, or zero if none is.
+	LatestVersion int64
+}
+
+// MigrateResult is like Migrate but also reports which migrations were
+// applied. If a migration fails, the result holds the migrations that were
+// applied before the failure.
+func (m *Migrator) MigrateResult() (Result, error) {
+	timeoutCtx, cancel := context.WithTimeout(context.Background(), m.options.migrationTimeout)
+	defer cancel()
+
+	return m.MigrateResultContext(timeoutCtx)
+}
+
+// MigrateResultContext is like MigrateResult but uses ctx for every database
+// call instead of the configured migration timeout.
+func (m *Migrator) MigrateResultContext(ctx context.Context) (Result, error) {
+	return m.migrate(ctx, math.MaxInt64)
+}
+
+func (m *Migrator) result(paths []string, knownMigrations migrationRows, appliedMigrations []string) (Result, error) {
+	result := Result{
+		Count:   len(appliedMigrations),
+		Applied: appliedMigrations,
+	}
+
+	for _, migrationPath := range slices.Backward(paths) {
+		migrationName := m.recordedName(knownMigrations, migrationPath)
+		migration, ok := m.findMigration(knownMigrations, migrationPath)
+		if !(ok && migration.IsApplied) && !slices.Contains(appliedMigrations, migrationName) {
+			continue
+		}
+
+		version, err := parseVersion(m.migrationFileName(migrationPath))
+		if err != nil {
+			return result, err
+		}
+		result.LatestVersion = version
+		break
+	}
+
+	return result, nil
+}
@@ -0,0 +1,82 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"time"
+)
+
+// minConnectBackoff is the shortest wait between two attempts to connect, so
+// a zero backoff does not hammer a database that is still starting.
+const minConnectBackoff = 10 * time.Millisecond
+
+// connectionError marks an error of checking out a connection from the pool,
+// which is always worth retrying.
+type connectionError struct {
+	err error
+}
+
+func (e *connectionError) Error() string {
+	return e.err.Error()
+}
+
+func (e *connectionError) Unwrap() error {
+	return e.err
+}
+
+// isConnectionError reports whether err means the connection to the database
+// failed, rather than a statement that the database refused.
+func isConnectionError(err error) bool {
+	var (
+		connErr *connectionError
+		netErr  net.Error
+	)
+	return errors.As(err, &connErr) ||
+		errors.As(err, &netErr) ||
+		errors.Is(err, driver.ErrBadConn) ||
+		errors.Is(err, sql.ErrConnDone) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// retryConnect calls attempt, which checks out a connection and prepares it,
+// for example by creating the migrations table. With WithConnectRetry, an
+// attempt that fails with a connection error is retried with exponential
+// backoff, so a Migrator started next to its database can wait for it to
+// accept connections. Errors of statements the database refused are returned
+// right away.
+func (m *Migrator) retryConnect(ctx context.Context, attempt func() error) error {
+	backoff := max(m.options.connectBackoff, minConnectBackoff)
+	for n := 1; ; n++ {
+		err := attempt()
+		if err == nil || !isConnectionError(err) || n >= m.options.connectAttempts || ctx.Err() != nil {
+			return err
+		}
+
+		m.options.logger.WarnContext(ctx, "failed to connect, retrying", "attempt", n, "backoff", backoff, "error", err)
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+		backoff *= 2
+	}
+}
+
+// acquireConn takes a connection from the pool, which opens a new connection
+// if the pool has no idle one.
+func (m *Migrator) acquireConn(ctx context.Context) (*sql.Conn, error) {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("get connection: %w", &connectionError{err: err})
+	}
+
+	return conn, nil
+}
@@ -0,0 +1,175 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"slices"
+	"time"
+)
+
+// Rollback reverses the last steps applied migrations, newest first, by
+// executing their ".down.sql" counterparts. It fails with ErrNoDownMigration
+// before executing anything if one of them has no down migration.
+//
+// Unless the transaction mode is NoTransaction or one of the down migrations
+// has a "-- migrate:no-transaction" directive, all down migrations and the
+// updates of their rows run in a single transaction, so a failing down
+// migration rolls back the whole Rollback. Otherwise each down migration is
+// marked dirty before it runs, like with Migrate, so a failing down migration
+// leaves the migration dirty.
+func (m *Migrator) Rollback(steps int) error {
+	timeoutCtx, cancel := context.WithTimeout(context.Background(), m.options.migrationTimeout)
+	defer cancel()
+
+	return m.RollbackContext(timeoutCtx, steps)
+}
+
+// RollbackContext is like Rollback but uses ctx for every database call
+// instead of the configured migration timeout.
+func (m *Migrator) RollbackContext(ctx context.Context, steps int) error {
+	if steps < 0 {
+		return fmt.Errorf("rollback: steps must not be negative, got %d", steps)
+	}
+
+	conn, release, err := m.connectLocked(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	knownMigrations, err := m.getMigrationsKnownToDb(conn, ctx)
+	if err != nil {
+		return err
+	}
+	if hasDirtyMigration(knownMigrations) {
+		return ErrDirtyMigration
+	}
+
+	paths, err := m.migrationFiles()
+	if err != nil {
+		return err
+	}
+
+	var (
+		rollbacks     []string
+		inTransaction = m.transactionMode() != NoTransaction
+	)
+	for _, migrationPath := range slices.Backward(paths) {
+		if len(rollbacks) == steps {
+			break
+		}
+
+		migration, ok := m.findMigration(knownMigrations, migrationPath)
+		if !ok || !migration.IsApplied {
+			continue
+		}
+
+		readBytes, err := fs.ReadFile(m.migrations, m.downMigrationPath(migrationPath))
+		if errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("migration %q: %w", m.migrationFileName(migrationPath), ErrNoDownMigration)
+		}
+		if err != nil {
+			return fmt.Errorf("read migration file %q: %w", m.downMigrationFileName(migrationPath), err)
+		}
+		if hasNoTransactionDirective(parseDirectives(readBytes)) {
+			inTransaction = false
+		}
+
+		rollbacks = append(rollbacks, migrationPath)
+	}
+
+	if !inTransaction {
+		for _, migrationPath := range rollbacks {
+			err := m.rollbackMigration(conn, ctx, knownMigrations, migrationPath)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	tx, err := conn.BeginTx(ctx, m.txOptions())
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, migrationPath := range rollbacks {
+		err := m.rollbackMigration(tx, ctx, knownMigrations, migrationPath)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// rollbackMigration executes the down migration of the migration at
+// migrationPath through db and marks the migration as not applied.
+func (m *Migrator) rollbackMigration(db execer, ctx context.Context, knownMigrations migrationRows, migrationPath string) error {
+	var (
+		downPath      = m.downMigrationPath(migrationPath)
+		migrationName = m.recordedName(knownMigrations, migrationPath)
+	)
+
+	migration, _ := m.findMigration(knownMigrations, migrationPath)
+
+	readBytes, err := fs.ReadFile(m.migrations, downPath)
+	if err != nil {
+		return fmt.Errorf("read migration file %q: %w", m.downMigrationFileName(migrationPath), err)
+	}
+
+	// Only the dirty flag changes, the row keeps when, by whom and how long
+	// the migration was applied until the rollback succeeded.
+	err = m.updateMigrationDirty(db, ctx, migrationName, true)
+	if err != nil {
+		return err
+	}
+
+	var (
+		logger = m.options.logger.With("migration", migrationName)
+		start  = time.Now()
+	)
+	logger.InfoContext(ctx, "rolling back migration")
+
+	err = m.execStatements(db, ctx, readBytes)
+	if err != nil {
+		logger.ErrorContext(ctx, "rollback failed", "duration", time.Since(start), "error", err)
+		return &MigrationError{Migration: migrationName, File: m.downMigrationFileName(migrationPath), Phase: PhaseRollback, Err: err}
+	}
+	logger.InfoContext(ctx, "rolled back migration", "duration", time.Since(start))
+
+	migration.MigrationName = migrationName
+	migration.IsApplied = false
+	migration.IsDirty = false
+	migration.AppliedBy = ""
+	migration.Duration = 0
+	err = m.upsertMigration(db, ctx, migration)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (m *Migrator) updateMigrationDirty(db execer, ctx context.Context, migrationName string, dirty bool) error {
+	var (
+		dialect = m.dialect()
+		query   = fmt.Sprintf("UPDATE %s SET is_dirty = %s WHERE migration_name = %s", m.table(), dialect.Placeholder(1), dialect.Placeholder(2))
+	)
+
+	_, err := db.ExecContext(ctx, query, dirty, migrationName)
+	if err != nil {
+		return fmt.Errorf("update dirty flag of migration %q: %w", migrationName, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,106 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+)
+
+// SchemaSelector is implemented by dialects that support WithSchema.
+type SchemaSelector interface {
+	// SelectSchemaQueries returns the statements that create schema if it
+	// does not exist and make it the default for the current connection.
+	SelectSchemaQueries(schema string) []string
+}
+
+// SchemaCreator is implemented by dialects that support WithTableSchema.
+type SchemaCreator interface {
+	// CreateSchemaQuery returns the statement that creates schema if it does
+	// not exist.
+	CreateSchemaQuery(schema string) string
+}
+
+func (d PostgresDialect) SelectSchemaQueries(schema string) []string {
+	return []string{
+		d.CreateSchemaQuery(schema),
+		fmt.Sprintf("SET search_path TO %s", schema),
+	}
+}
+
+func (PostgresDialect) CreateSchemaQuery(schema string) string {
+	return fmt.Sprintf("CREATE SCHEMA IF NOT EXISTS %s", schema)
+}
+
+// SelectSchemaQueries treats schema as a database, as MySQL does.
+func (d MySQLDialect) SelectSchemaQueries(schema string) []string {
+	return []string{
+		d.CreateSchemaQuery(schema),
+		fmt.Sprintf("USE %s", schema),
+	}
+}
+
+// CreateSchemaQuery treats schema as a database, as MySQL does.
+func (MySQLDialect) CreateSchemaQuery(schema string) string {
+	return fmt.Sprintf("CREATE DATABASE IF NOT EXISTS %s", schema)
+}
+
+func (m *Migrator) selectSchema(db execer, ctx context.Context) error {
+	selector, ok := m.dialect().(SchemaSelector)
+	if !ok {
+		return fmt.Errorf("select schema %q: dialect %T does not support schemas", m.options.schema, m.dialect())
+	}
+
+	for _, query := range selector.SelectSchemaQueries(m.options.schema) {
+		_, err := db.ExecContext(ctx, query)
+		if err != nil {
+			return fmt.Errorf("select schema %q: %w", m.options.schema, err)
+		}
+	}
+
+	return nil
+}
+
+func (m *Migrator) createTableSchema(db execer, ctx context.Context) error {
+	creator, ok := m.dialect().(SchemaCreator)
+	if !ok {
+		return fmt.Errorf("create table schema %q: dialect %T does not support schemas", m.options.tableSchema, m.dialect())
+	}
+
+	_, err := db.ExecContext(ctx, creator.CreateSchemaQuery(m.options.tableSchema))
+	if err != nil {
+		return fmt.Errorf("create table schema %q: %w", m.options.tableSchema, err)
+	}
+
+	return nil
+}
+
+// table returns the name of the migrations table as used in queries,
+// qualified with the schema set with WithTableSchema.
+func (m *Migrator) table() string {
+	if m.options.tableSchema != "" {
+		return m.options.tableSchema + "." + m.options.tableName
+	}
+
+	return m.options.tableName
+}
+
+// closeConn returns conn to the pool. A connection that had its schema
+// changed is discarded instead, so the schema does not leak into queries
+// of the application that get the connection next.
+func (m *Migrator) closeConn(conn *sql.Conn) {
+	if m.options.schema != "" {
+		_ = conn.Raw(func(any) error { return driver.ErrBadConn })
+	}
+	_ = conn.Close()
+}
+
+// lockName is the name the migration lock is keyed by, which includes the
+// schema so migrators of different schemas do not wait for each other.
+func (m *Migrator) lockName() string {
+	if m.options.schema != "" && m.options.tableSchema == "" {
+		return m.options.schema + "." + m.options.tableName
+	}
+
+	return m.table()
+}
@@ -0,0 +1,164 @@
+package migrate
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"slices"
+	"strings"
+)
+
+// VersionConflictPolicy controls what Migrate does when several migration
+// filesystems, the one passed to NewMigrator and those added with
+// WithAdditionalFS, provide a migration with the same version.
+type VersionConflictPolicy int
+
+const (
+	// VersionConflictError fails with ErrDuplicateMigrationVersion, or with
+	// ErrDuplicateMigrationName for files at the same path, unless their
+	// content is identical.
+	VersionConflictError VersionConflictPolicy = iota
+	// VersionConflictFirst uses the migration of the filesystem added first,
+	// starting with the one passed to NewMigrator.
+	VersionConflictFirst
+	// VersionConflictLast uses the migration of the filesystem added last, so
+	// a filesystem can override a migration of a base one.
+	VersionConflictLast
+)
+
+// mergedFS presents the migrations filesystem and those added with
+// WithAdditionalFS as one, so their migrations are ordered by version
+// together. With VersionConflictError, a file found at the same path in
+// several of them must have the same content, as it is the same migration
+// shipped twice.
+type mergedFS struct {
+	sources []fs.FS
+	policy  VersionConflictPolicy
+}
+
+// Open opens name in the filesystem that provides it: the last one that has it
+// with VersionConflictLast, the first one otherwise. Files that differ between
+// the filesystems are reported by ReadDir, which walking the migrations calls
+// before any file is read.
+func (f mergedFS) Open(name string) (fs.File, error) {
+	i := f.source(name)
+	if i < 0 {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+
+	return f.sources[i].Open(name)
+}
+
+// source returns the index of the filesystem providing name, or -1 if none
+// has it.
+func (f mergedFS) source(name string) int {
+	found := -1
+	for i, fsys := range f.sources {
+		_, err := fs.Stat(fsys, name)
+		if err != nil {
+			continue
+		}
+		if f.policy != VersionConflictLast {
+			return i
+		}
+		found = i
+	}
+
+	return found
+}
+
+// ReadDir returns the entries of the directory name in every filesystem that
+// has it, sorted by name. Directories of the same name are merged.
+func (f mergedFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	var (
+		entries []fs.DirEntry
+		sources []fs.FS
+		indexes = map[string]int{}
+		found   bool
+	)
+	for _, fsys := range f.sources {
+		dirEntries, err := fs.ReadDir(fsys, name)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		found = true
+
+		for _, entry := range dirEntries {
+			i, ok := indexes[entry.Name()]
+			if !ok {
+				indexes[entry.Name()] = len(entries)
+				entries = append(entries, entry)
+				sources = append(sources, fsys)
+				continue
+			}
+			if (entry.IsDir() && entries[i].IsDir()) || f.policy != VersionConflictError {
+				continue
+			}
+
+			err = sameFile(sources[i], fsys, path.Join(name, entry.Name()))
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	if !found {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+
+	slices.SortFunc(entries, func(a, b fs.DirEntry) int {
+		return strings.Compare(a.Name(), b.Name())
+	})
+	return entries, nil
+}
+
+// sameFile returns an error unless name has the same content in a and b.
+func sameFile(a, b fs.FS, name string) error {
+	contentA, err := fs.ReadFile(a, name)
+	if err != nil {
+		return err
+	}
+	contentB, err := fs.ReadFile(b, name)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(contentA, contentB) {
+		return fmt.Errorf("%q differs between migration filesystems: %w", name, ErrDuplicateMigrationName)
+	}
+
+	return nil
+}
+
+// resolveVersionConflict returns which of two paths with the same version,
+// a sorted before b, to keep according to the VersionConflictPolicy. Paths
+// from the same filesystem, or Go migrations, always conflict.
+func (m *Migrator) resolveVersionConflict(a, b string) (string, error) {
+	conflict := fmt.Errorf("%q and %q: %w", a, b, ErrDuplicateMigrationVersion)
+
+	merged, ok := m.migrations.(mergedFS)
+	if !ok || merged.policy == VersionConflictError {
+		return "", conflict
+	}
+	if _, ok := goMigrationName(a); ok {
+		return "", conflict
+	}
+	if _, ok := goMigrationName(b); ok {
+		return "", conflict
+	}
+
+	sourceA, sourceB := merged.source(a), merged.source(b)
+	switch {
+	case sourceA == sourceB:
+		return "", conflict
+	case (merged.policy == VersionConflictFirst) == (sourceA < sourceB):
+		m.options.logger.Debug("overrode migration with the same version", "migration", a, "overridden", b)
+		return a, nil
+	default:
+		m.options.logger.Debug("overrode migration with the same version", "migration", b, "overridden", a)
+		return b, nil
+	}
+}
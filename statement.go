@@ -0,0 +1,216 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// SplitStatements is a statement splitter for WithStatementSplitter. It splits
+// sql at every semicolon outside of string literals, quoted identifiers,
+// comments and PostgreSQL dollar-quoted blocks, so a function body such as
+// $$ BEGIN ...; END $$ stays in one statement. Statements that only consist of
+// whitespace and comments are dropped.
+func SplitStatements(sql string) []string {
+	return splitStatements(sql, ";", standardSyntax)
+}
+
+// syntax describes the lexical rules a statement splitter has to know to tell
+// a delimiter from one inside a string literal or comment.
+type syntax struct {
+	// backslashEscapes makes a backslash escape the next character in string
+	// literals, e.g. 'it\'s'.
+	backslashEscapes bool
+	// hashComments makes # start a comment that ends with the line.
+	hashComments bool
+	// dollarQuotes makes $$ or $tag$ quote everything up to the next tag.
+	dollarQuotes bool
+}
+
+var (
+	// standardSyntax is SQL as PostgreSQL and SQLite understand it.
+	standardSyntax = syntax{dollarQuotes: true}
+	// mysqlSyntax is SQL as MySQL understands it in its default SQL mode.
+	mysqlSyntax = syntax{backslashEscapes: true, hashComments: true}
+)
+
+// splitStatements splits sql at every delimiter outside of string literals,
+// quoted identifiers and comments, given the rules of syn, and drops the
+// statements that only consist of whitespace and comments.
+func splitStatements(sql string, delimiter string, syn syntax) []string {
+	var (
+		statements []string
+		start      int
+		hasCode    bool
+	)
+	for i := 0; i < len(sql); {
+		c := sql[i]
+		switch {
+		case c == '\'' || c == '"':
+			i = skipQuoted(sql, i, c, syn.backslashEscapes)
+			hasCode = true
+		case c == '`':
+			i = skipQuoted(sql, i, c, false)
+			hasCode = true
+		case strings.HasPrefix(sql[i:], "--"), syn.hashComments && c == '#':
+			i = skipPast(sql, i, "\n")
+		case strings.HasPrefix(sql[i:], "/*"):
+			i = skipPast(sql, i+2, "*/")
+		case syn.dollarQuotes && c == '$':
+			tag, ok := dollarQuoteTag(sql[i:])
+			if ok {
+				i = skipPast(sql, i+len(tag), tag)
+			} else {
+				i++
+			}
+			hasCode = true
+		case strings.HasPrefix(sql[i:], delimiter):
+			if hasCode {
+				statements = append(statements, strings.TrimSpace(sql[start:i]))
+			}
+			i += len(delimiter)
+			start = i
+			hasCode = false
+		default:
+			if !isSpace(c) {
+				hasCode = true
+			}
+			i++
+		}
+	}
+	if hasCode {
+		statements = append(statements, strings.TrimSpace(sql[start:]))
+	}
+
+	return statements
+}
+
+// skipQuoted returns the index after the quoted string starting at i. A
+// doubled quote character escapes itself, and with backslashEscapes a
+// backslash escapes the character after it.
+func skipQuoted(sql string, i int, quote byte, backslashEscapes bool) int {
+	for i++; i < len(sql); i++ {
+		if backslashEscapes && sql[i] == '\\' {
+			i++
+			continue
+		}
+		if sql[i] != quote {
+			continue
+		}
+		if i+1 < len(sql) && sql[i+1] == quote {
+			i++
+			continue
+		}
+		return i + 1
+	}
+	return len(sql)
+}
+
+// skipPast returns the index after the first end found at or after i, or the
+// end of sql if there is none.
+func skipPast(sql string, i int, end string) int {
+	n := strings.Index(sql[i:], end)
+	if n < 0 {
+		return len(sql)
+	}
+	return i + n + len(end)
+}
+
+// dollarQuoteTag returns the tag, such as "$$" or "$body$", that sql starts
+// with. A "$1" parameter placeholder is not a tag.
+func dollarQuoteTag(sql string) (string, bool) {
+	for i := 1; i < len(sql); i++ {
+		c := sql[i]
+		switch {
+		case c == '$':
+			return sql[:i+1], true
+		case c == '_' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || i > 1 && '0' <= c && c <= '9':
+		default:
+			return "", false
+		}
+	}
+	return "", false
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+// BodyExecutor is implemented by dialects that control how the body of a SQL
+// migration is executed, e.g. to split it into statements for a driver that
+// only accepts one per query. Migrations on a dialect that is not a
+// BodyExecutor are sent to the database as one query.
+type BodyExecutor interface {
+	// ExecBody executes body, the content of a migration file, on db, which
+	// is the connection or transaction the migration runs in.
+	ExecBody(ctx context.Context, db Execer, body string) error
+}
+
+// ExecBody executes the statements of body one at a time, so the DSN does not
+// need multiStatements=true. As with the mysql client, a line such as
+// "DELIMITER //" changes the delimiter, so compound statements such as
+// CREATE PROCEDURE ... BEGIN ...; END // are executed as one, and
+// "DELIMITER ;" changes it back.
+func (MySQLDialect) ExecBody(ctx context.Context, db Execer, body string) error {
+	statements := splitMySQLStatements(body)
+	for i, statement := range statements {
+		_, err := db.ExecContext(ctx, statement)
+		if err != nil {
+			return fmt.Errorf("statement %d of %d: %w", i+1, len(statements), err)
+		}
+	}
+
+	return nil
+}
+
+// splitMySQLStatements splits body at semicolons, or after a DELIMITER line at
+// the delimiter it sets, following the lexical rules of MySQL: backslashes
+// escape characters in string literals and # starts a comment.
+func splitMySQLStatements(body string) []string {
+	var (
+		statements []string
+		delimiter  = ";"
+		part       strings.Builder
+	)
+	flush := func() {
+		statements = append(statements, splitStatements(part.String(), delimiter, mysqlSyntax)...)
+		part.Reset()
+	}
+
+	for _, line := range strings.SplitAfter(body, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && strings.EqualFold(fields[0], "DELIMITER") {
+			flush()
+			delimiter = fields[1]
+			continue
+		}
+		part.WriteString(line)
+	}
+	flush()
+
+	return statements
+}
+
+// execStatements executes the statements of a migration one at a time if a
+// statement splitter is configured, has the dialect execute them if it is a
+// BodyExecutor, or executes the whole migration at once otherwise.
+func (m *Migrator) execStatements(db execer, ctx context.Context, content []byte) error {
+	if m.options.statementSplitter == nil {
+		if executor, ok := m.dialect().(BodyExecutor); ok {
+			return executor.ExecBody(ctx, db, string(content))
+		}
+
+		_, err := db.ExecContext(ctx, string(content))
+		return err
+	}
+
+	statements := m.options.statementSplitter(string(content))
+	for i, statement := range statements {
+		_, err := db.ExecContext(ctx, statement)
+		if err != nil {
+			return fmt.Errorf("statement %d of %d: %w", i+1, len(statements), err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,127 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// MigrationState is the state of a migration as reported by Status.
+type MigrationState string
+
+const (
+	// MigrationApplied is a migration that has been applied to the database.
+	MigrationApplied MigrationState = "applied"
+	// MigrationPending is a migration file that has not been applied yet.
+	MigrationPending MigrationState = "pending"
+	// MigrationMissing is a migration known to the database whose file no
+	// longer exists.
+	MigrationMissing MigrationState = "missing"
+)
+
+// MigrationStatus describes a single migration as reported by Status.
+type MigrationStatus struct {
+	Name string `json:"name"`
+	// Hash is the hash of the migration file, or the stored hash if the file
+	// is missing.
+	Hash      string         `json:"hash"`
+	State     MigrationState `json:"state"`
+	Applied   bool           `json:"applied"`
+	Dirty     bool           `json:"dirty"`
+	AppliedAt time.Time      `json:"applied_at,omitzero"`
+	// AppliedBy is the value of WithAppliedBy of the run that applied the
+	// migration, if it was set.
+	AppliedBy string `json:"applied_by,omitempty"`
+	// Duration is how long the migration took to execute when it was last
+	// applied, with millisecond precision.
+	Duration time.Duration `json:"duration,omitempty"`
+	// Description is taken from the "-- migrate:description" directive of
+	// the migration file, and is empty if the file is missing.
+	Description string `json:"description,omitempty"`
+}
+
+// Status reports every migration file in the order it is applied, followed by
+// the migrations known to the database whose files are missing. It executes no
+// migrations and only ensures the migrations table exists.
+func (m *Migrator) Status() ([]MigrationStatus, error) {
+	timeoutCtx, cancel := context.WithTimeout(context.Background(), m.options.migrationTimeout)
+	defer cancel()
+
+	return m.StatusContext(timeoutCtx)
+}
+
+// StatusContext is like Status but uses ctx for every database call instead
+// of the configured migration timeout.
+func (m *Migrator) StatusContext(ctx context.Context) ([]MigrationStatus, error) {
+	conn, err := m.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer m.closeConn(conn)
+
+	knownMigrations, err := m.getMigrationsKnownToDb(conn, ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	paths, err := m.migrationFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		statuses = make([]MigrationStatus, 0, len(paths))
+		onDisk   = make(map[string]bool, len(paths))
+	)
+	for _, migrationPath := range paths {
+		migrationName := m.recordedName(knownMigrations, migrationPath)
+		onDisk[migrationName] = true
+
+		readBytes, err := m.readMigration(migrationPath)
+		if err != nil {
+			return nil, fmt.Errorf("read migration file %q: %w", m.migrationFileName(migrationPath), err)
+		}
+
+		migrationHash, err := m.hashMigration(readBytes)
+		if err != nil {
+			return nil, fmt.Errorf("hash migration %q: %w", m.migrationFileName(migrationPath), err)
+		}
+
+		status := MigrationStatus{
+			Name:        migrationName,
+			Hash:        migrationHash,
+			State:       MigrationPending,
+			Description: migrationDescription(parseDirectives(readBytes)),
+		}
+		if migration, ok := m.findMigration(knownMigrations, migrationPath); ok {
+			status.Applied = migration.IsApplied
+			status.Dirty = migration.IsDirty
+			status.AppliedAt = migration.AppliedAt
+			status.AppliedBy = migration.AppliedBy
+			status.Duration = migration.Duration
+			if migration.IsApplied {
+				status.State = MigrationApplied
+			}
+		}
+		statuses = append(statuses, status)
+	}
+
+	for _, migration := range knownMigrations.rows {
+		if onDisk[migration.MigrationName] {
+			continue
+		}
+
+		statuses = append(statuses, MigrationStatus{
+			Name:      migration.MigrationName,
+			Hash:      migration.MigrationHash,
+			State:     MigrationMissing,
+			Applied:   migration.IsApplied,
+			Dirty:     migration.IsDirty,
+			AppliedAt: migration.AppliedAt,
+			AppliedBy: migration.AppliedBy,
+			Duration:  migration.Duration,
+		})
+	}
+
+	return statuses, nil
+}
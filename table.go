@@ -0,0 +1,244 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// requiredColumns are the columns a migrations table must have. The
+// migrations table may have more, but not fewer.
+var requiredColumns = []string{"migration_name", "migration_hash", "is_applied", "is_dirty", "applied_at"}
+
+// ownColumns are the columns of the migrations table in every version. A table
+// without them was created by another tool, such as the schema_migrations
+// table of golang-migrate.
+var ownColumns = []string{"migration_name", "migration_hash", "is_applied", "is_dirty"}
+
+// addedColumns are columns added after requiredColumns, with their type.
+// Tables that lack them are upgraded in place.
+var addedColumns = [][2]string{
+	{"description", "TEXT"},
+	{"applied_by", "TEXT"},
+	{"duration_ms", "BIGINT NOT NULL DEFAULT 0"},
+}
+
+// migrationColumns are the columns the Migrator reads and writes, in the
+// order of the parameters of Dialect.UpsertQuery.
+var migrationColumns = append(slices.Clone(requiredColumns), "description", "applied_by", "duration_ms")
+
+// columnKind is the kind of value the Migrator scans from a column.
+type columnKind string
+
+const (
+	textColumn      columnKind = "text"
+	boolColumn      columnKind = "boolean"
+	timestampColumn columnKind = "timestamp"
+	integerColumn   columnKind = "integer"
+)
+
+// columnKinds are the kinds of the migrationColumns.
+var columnKinds = map[string]columnKind{
+	"migration_name": textColumn,
+	"migration_hash": textColumn,
+	"is_applied":     boolColumn,
+	"is_dirty":       boolColumn,
+	"applied_at":     timestampColumn,
+	"description":    textColumn,
+	"applied_by":     textColumn,
+	"duration_ms":    integerColumn,
+}
+
+// accepts reports whether a column of databaseType, as reported by the
+// driver, scans into a value of kind k. Flags may be the TINYINT(1) MySQL
+// turns BOOLEAN into.
+func (k columnKind) accepts(databaseType string) bool {
+	databaseType = strings.ToUpper(databaseType)
+	containsAny := func(names ...string) bool {
+		return slices.ContainsFunc(names, func(name string) bool { return strings.Contains(databaseType, name) })
+	}
+
+	switch k {
+	case textColumn:
+		return containsAny("CHAR", "TEXT", "CLOB", "STRING")
+	case boolColumn:
+		return containsAny("BOOL", "TINYINT", "BIT")
+	case timestampColumn:
+		return containsAny("TIME", "DATE")
+	case integerColumn:
+		return containsAny("INT", "NUMERIC", "DECIMAL")
+	default:
+		return false
+	}
+}
+
+// tableColumn is a column of the migrations table with the database type its
+// driver reports, which is empty if the driver does not know it.
+type tableColumn struct {
+	name         string
+	databaseType string
+}
+
+// checkForeignTable refuses an existing migrations table that was not created
+// by this package, so it is neither altered nor misread.
+func (m *Migrator) checkForeignTable(db querier, ctx context.Context) error {
+	table := m.table()
+
+	columns, err := tableColumns(db, ctx, table)
+	if err != nil {
+		return err
+	}
+
+	var missing []string
+	for _, column := range ownColumns {
+		if !slices.Contains(columns, column) {
+			missing = append(missing, column)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("migrations table %q is missing columns %s, it has %s, so it probably belongs to another tool; choose a table of its own with WithTableName: %w", table, strings.Join(missing, ", "), strings.Join(columns, ", "), ErrInvalidMigrationsTable)
+	}
+
+	return nil
+}
+
+// validateTableDDL checks that a statement set with WithTableDDL mentions
+// every required column; validateTable adds the others. Whether it actually
+// creates them is only known once it has run, when validateTable checks the
+// table itself.
+func validateTableDDL(ddl string) error {
+	var (
+		lower   = strings.ToLower(ddl)
+		missing []string
+	)
+	for _, column := range requiredColumns {
+		if !strings.Contains(lower, column) {
+			missing = append(missing, column)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("invalid table DDL: missing columns %s: %w", strings.Join(missing, ", "), ErrInvalidMigrationsTable)
+	}
+
+	return nil
+}
+
+// validateTable checks that the migrations table has every column the
+// Migrator needs, with a type its values scan from, so a table created by
+// hand or by another tool is reported clearly instead of failing on the first
+// scan. Types the driver does not report are not checked. Columns added in
+// later versions are added to older tables.
+func (m *Migrator) validateTable(db querier, ctx context.Context) error {
+	table := m.table()
+
+	described, err := describeTable(db, ctx, table)
+	if err != nil {
+		return err
+	}
+	columns := columnNames(described)
+
+	var missing []string
+	for _, column := range requiredColumns {
+		if !slices.Contains(columns, column) {
+			missing = append(missing, column)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("migrations table %q is missing columns %s, it has %s: %w", table, strings.Join(missing, ", "), strings.Join(columns, ", "), ErrInvalidMigrationsTable)
+	}
+
+	var mismatched []string
+	for _, column := range described {
+		kind, ok := columnKinds[column.name]
+		if !ok || column.databaseType == "" || kind.accepts(column.databaseType) {
+			continue
+		}
+		mismatched = append(mismatched, fmt.Sprintf("%s is %s instead of a %s type", column.name, column.databaseType, kind))
+	}
+	if len(mismatched) > 0 {
+		return fmt.Errorf("migrations table %q has columns of unexpected types: %s: %w", table, strings.Join(mismatched, ", "), ErrInvalidMigrationsTable)
+	}
+
+	for _, column := range addedColumns {
+		if slices.Contains(columns, column[0]) {
+			continue
+		}
+
+		_, err = db.ExecContext(ctx, fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column[0], column[1]))
+		if err != nil {
+			return fmt.Errorf("add column %s to migrations table %q: %w", column[0], table, err)
+		}
+	}
+
+	return nil
+}
+
+// readMigrationsKnownToDb is getMigrationsKnownToDb for Verify, Applied and
+// DriftReport, which only read, in a read-only transaction, and so do not
+// upgrade the migrations table either. A table that still lacks some of the
+// addedColumns is read with NULL in their place, as if the migrations had
+// been recorded without them.
+func (m *Migrator) readMigrationsKnownToDb(conn *sql.Conn, ctx context.Context) (migrationRows, error) {
+	tx, err := conn.BeginTx(ctx, m.readOnlyTxOptions())
+	if err != nil {
+		return migrationRows{}, fmt.Errorf("begin read-only transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	table := m.table()
+
+	columns, err := tableColumns(tx, ctx, table)
+	if err != nil {
+		return migrationRows{}, err
+	}
+
+	for _, column := range addedColumns {
+		if !slices.Contains(columns, column[0]) {
+			nulls := strings.Repeat(", NULL", len(migrationColumns)-len(requiredColumns))
+			return m.queryMigrations(tx, ctx, fmt.Sprintf("SELECT %s%s FROM %s ORDER BY migration_name", strings.Join(requiredColumns, ", "), nulls, table))
+		}
+	}
+
+	return m.getMigrationsKnownToDb(tx, ctx)
+}
+
+// tableColumns returns the lower case column names of table.
+func tableColumns(db querier, ctx context.Context, table string) ([]string, error) {
+	described, err := describeTable(db, ctx, table)
+	if err != nil {
+		return nil, err
+	}
+
+	return columnNames(described), nil
+}
+
+// describeTable returns the columns of table with their lower case names.
+func describeTable(db querier, ctx context.Context, table string) ([]tableColumn, error) {
+	rows, err := db.QueryContext(ctx, fmt.Sprintf("SELECT * FROM %s WHERE 1 = 0", table))
+	if err != nil {
+		return nil, fmt.Errorf("inspect migrations table %q: %w", table, err)
+	}
+	defer rows.Close()
+
+	columnTypes, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, fmt.Errorf("inspect migrations table %q: %w", table, err)
+	}
+
+	columns := make([]tableColumn, 0, len(columnTypes))
+	for _, columnType := range columnTypes {
+		columns = append(columns, tableColumn{name: strings.ToLower(columnType.Name()), databaseType: columnType.DatabaseTypeName()})
+	}
+
+	return columns, nil
+}
+
+func columnNames(columns []tableColumn) []string {
+	names := make([]string, 0, len(columns))
+	for _, column := range columns {
+		names = append(names, column.name)
+	}
+	return names
+}
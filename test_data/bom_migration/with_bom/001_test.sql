@@ -0,0 +1,6 @@
+﻿CREATE TABLE IF NOT EXISTS test (   
+    id INT PRIMARY KEY,
+    name VARCHAR(100)
+);
+
+
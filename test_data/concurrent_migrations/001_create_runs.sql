@@ -0,0 +1,3 @@
+CREATE TABLE migration_runs (
+    id INTEGER
+);
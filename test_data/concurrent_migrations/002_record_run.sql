@@ -0,0 +1 @@
+INSERT INTO migration_runs (id) VALUES (1);
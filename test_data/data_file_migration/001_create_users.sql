@@ -0,0 +1,5 @@
+-- migrate:data-file users.csv users
+CREATE TABLE IF NOT EXISTS users (
+    id INT PRIMARY KEY,
+    name VARCHAR(100)
+);
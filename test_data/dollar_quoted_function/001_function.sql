@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS test (
+    id INT PRIMARY KEY,
+    name VARCHAR(100)
+);
+
+CREATE FUNCTION add_test(new_id INT) RETURNS void AS $$
+BEGIN
+    INSERT INTO test (id, name) VALUES (new_id, 'a;b');
+END;
+$$ LANGUAGE plpgsql;
+
+SELECT add_test(1);
@@ -0,0 +1,3 @@
+DELETE FROM test WHERE id = 2;
+DELETE FROM test WHERE id = 1;
+DROP TABLE test;
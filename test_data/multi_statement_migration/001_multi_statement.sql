@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS test (
+    id INT PRIMARY KEY,
+    name VARCHAR(100)
+);
+
+INSERT INTO test (id, name) VALUES (1, 'alice');
+INSERT INTO test (id, name) VALUES (2, 'bob');
@@ -0,0 +1,5 @@
+-- migrate:no-transaction
+CREATE TABLE IF NOT EXISTS invalid (
+    id INT PRIMARY KEY,, --Double comma
+    name VARCHAR(100)
+);
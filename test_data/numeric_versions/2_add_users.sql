@@ -0,0 +1,4 @@
+CREATE TABLE IF NOT EXISTS users (
+    id INT PRIMARY KEY,
+    email VARCHAR(255)
+);
@@ -1,4 +1,4 @@
 CREATE TABLE IF NOT EXISTS test (
     id INT PRIARY KEY, --Typo
-    name VARCHAR(100)
+    name VARCHAR(100),, --Typo, as SQLite tolerates the one above
 );
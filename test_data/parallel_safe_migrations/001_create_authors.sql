@@ -0,0 +1,4 @@
+-- migrate:parallel-safe
+CREATE TABLE IF NOT EXISTS authors (
+    id INT PRIMARY KEY
+);
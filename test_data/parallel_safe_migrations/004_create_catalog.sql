@@ -0,0 +1,3 @@
+CREATE VIEW catalog AS
+SELECT authors.id AS author_id, books.id AS book_id, shelves.id AS shelf_id
+FROM authors, books, shelves;
@@ -0,0 +1,4 @@
+CREATE TABLE IF NOT EXISTS invalid (
+    id INT PRIMARY KEY,, --Double comma
+    name VARCHAR(100)
+);
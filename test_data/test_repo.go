@@ -2,36 +2,49 @@ package test_data
 
 import (
 	"database/sql"
+	"fmt"
+	"time"
 )
 
 type migrationRow struct {
-	MigrationName string `json:"migration_name,omitempty"`
-	MigrationHash string `json:"migration_hash,omitempty"`
-	IsApplied     bool   `json:"is_applied,omitempty"`
-	IsDirty       bool   `json:"is_dirty,omitempty"`
+	MigrationName string    `json:"migration_name,omitempty"`
+	MigrationHash string    `json:"migration_hash,omitempty"`
+	IsApplied     bool      `json:"is_applied,omitempty"`
+	IsDirty       bool      `json:"is_dirty,omitempty"`
+	AppliedAt     time.Time `json:"applied_at,omitempty"`
 }
 
 type TestRepo struct {
-	db *sql.DB
+	db    *sql.DB
+	table string
 }
 
 func NewRepo(db *sql.DB) *TestRepo {
+	return NewRepoForTable(db, "migrations")
+}
+
+func NewRepoForTable(db *sql.DB, table string) *TestRepo {
 	return &TestRepo{
-		db: db,
+		db:    db,
+		table: table,
 	}
 }
 
 func (r *TestRepo) GetMigrationByName(name string) migrationRow {
-	var row migrationRow
-	err := r.db.QueryRow("SELECT migration_name, migration_hash, is_applied, is_dirty FROM migrations WHERE migration_name = $1", name).Scan(&row.MigrationName, &row.MigrationHash, &row.IsApplied, &row.IsDirty)
+	var (
+		row       migrationRow
+		appliedAt sql.NullTime
+	)
+	err := r.db.QueryRow(fmt.Sprintf("SELECT migration_name, migration_hash, is_applied, is_dirty, applied_at FROM %s WHERE migration_name = $1", r.table), name).Scan(&row.MigrationName, &row.MigrationHash, &row.IsApplied, &row.IsDirty, &appliedAt)
 	if err != nil {
 		return migrationRow{}
 	}
+	row.AppliedAt = appliedAt.Time
 	return row
 }
 
 func (r *TestRepo) GetAllMigrations() ([]migrationRow, error) {
-	rows, err := r.db.Query("SELECT migration_name, migration_hash, is_applied, is_dirty FROM migrations")
+	rows, err := r.db.Query(fmt.Sprintf("SELECT migration_name, migration_hash, is_applied, is_dirty, applied_at FROM %s", r.table))
 	if err != nil {
 		return nil, err
 	}
@@ -39,10 +52,14 @@ func (r *TestRepo) GetAllMigrations() ([]migrationRow, error) {
 
 	var migrations []migrationRow
 	for rows.Next() {
-		var row migrationRow
-		if err := rows.Scan(&row.MigrationName, &row.MigrationHash, &row.IsApplied, &row.IsDirty); err != nil {
+		var (
+			row       migrationRow
+			appliedAt sql.NullTime
+		)
+		if err := rows.Scan(&row.MigrationName, &row.MigrationHash, &row.IsApplied, &row.IsDirty, &appliedAt); err != nil {
 			return nil, err
 		}
+		row.AppliedAt = appliedAt.Time
 		migrations = append(migrations, row)
 	}
 	return migrations, nil
@@ -0,0 +1,3 @@
+CREATE TABLE IF NOT EXISTS events (
+    id INT PRIMARY KEY
+);
@@ -0,0 +1,248 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// noTransactionDirective makes a migration run outside a transaction even in
+// PerMigration mode, for statements such as CREATE INDEX CONCURRENTLY that
+// PostgreSQL refuses to run in one, e.g.
+//
+//	-- migrate:no-transaction
+const noTransactionDirective = "no-transaction"
+
+// savepointName is the savepoint every migration runs under with
+// WithSavepoints. It is released after each migration, so one name suffices.
+const savepointName = "go_migrate_migration"
+
+// TransactionMode controls whether Migrate wraps migrations in transactions.
+type TransactionMode int
+
+const (
+	// NoTransaction executes every migration directly on the connection. A
+	// failing migration is left dirty and earlier migrations stay applied.
+	NoTransaction TransactionMode = iota
+	// PerMigration executes every migration and its bookkeeping in its own
+	// transaction. A failing migration is rolled back without being marked
+	// dirty, while earlier migrations stay applied. Migrations with a
+	// "-- migrate:no-transaction" directive run as with NoTransaction.
+	PerMigration
+	// AllInOne executes all pending migrations in a single transaction, so a
+	// failing migration rolls back every migration of the run. Migrations
+	// are applied one at a time regardless of the configured parallelism, and
+	// migrations with a "-- migrate:no-transaction" directive are refused.
+	AllInOne
+)
+
+// TransactionalDDLReporter is implemented by dialects that can tell whether
+// their database rolls back DDL statements together with the transaction
+// they ran in. Dialects that do not implement it are assumed to.
+type TransactionalDDLReporter interface {
+	// TransactionalDDL reports whether DDL statements can be rolled back.
+	TransactionalDDL() bool
+}
+
+// TransactionalDDL reports true, as PostgreSQL rolls back DDL like any other
+// statement.
+func (PostgresDialect) TransactionalDDL() bool {
+	return true
+}
+
+// TransactionalDDL reports false, as MySQL commits the transaction implicitly
+// before and after every DDL statement. A dialect for migrations that never
+// change the schema can embed MySQLDialect and return true instead.
+func (MySQLDialect) TransactionalDDL() bool {
+	return false
+}
+
+func (SQLiteDialect) TransactionalDDL() bool {
+	return true
+}
+
+// transactionalDDL reports whether the dialect can roll back DDL statements.
+func (m *Migrator) transactionalDDL() bool {
+	reporter, ok := m.dialect().(TransactionalDDLReporter)
+	return !ok || reporter.TransactionalDDL()
+}
+
+// transactionMode returns the configured TransactionMode, PerMigration instead
+// of AllInOne with WithKeepSucceeded, or NoTransaction if the database cannot
+// roll back DDL. A transaction would then roll back the
+// bookkeeping of a failing migration but not its DDL, so the next run would
+// execute it again, while outside one the migration is left dirty.
+func (m *Migrator) transactionMode() TransactionMode {
+	if !m.transactionalDDL() {
+		return NoTransaction
+	}
+	if m.options.keepSucceeded && m.options.transactionMode == AllInOne {
+		return PerMigration
+	}
+
+	return m.options.transactionMode
+}
+
+// Execer is implemented by both *sql.Conn and *sql.Tx, so migrations can be
+// executed inside or outside a transaction.
+type Execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+type execer = Execer
+
+// querier is implemented by both *sql.Conn and *sql.Tx, so the migrations
+// table can be read inside or outside a transaction.
+type querier interface {
+	execer
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// txOptions returns the options to begin the transactions of migrations with,
+// or nil for the defaults of the driver.
+func (m *Migrator) txOptions() *sql.TxOptions {
+	if m.options.isolationLevel == sql.LevelDefault {
+		return nil
+	}
+
+	return &sql.TxOptions{Isolation: m.options.isolationLevel}
+}
+
+// readOnlyTxOptions returns the options to begin the transaction Verify,
+// Applied and DriftReport read the migrations table in, so a database that
+// enforces read-only transactions refuses any write.
+func (m *Migrator) readOnlyTxOptions() *sql.TxOptions {
+	return &sql.TxOptions{Isolation: m.options.isolationLevel, ReadOnly: true}
+}
+
+func (p pendingMigration) isNoTransaction() bool {
+	return hasNoTransactionDirective(p.directives)
+}
+
+func hasNoTransactionDirective(directives []directive) bool {
+	return slices.ContainsFunc(directives, func(d directive) bool {
+		return d.name == noTransactionDirective
+	})
+}
+
+// applyInTransaction applies the pending migrations in a single transaction
+// and returns their names once it has been committed.
+func (m *Migrator) applyInTransaction(conn *sql.Conn, ctx context.Context, pending []pendingMigration) ([]string, error) {
+	for _, migration := range pending {
+		if migration.isNoTransaction() {
+			return nil, fmt.Errorf("migration %q: %s directive cannot be used with AllInOne", m.migrationFileName(migration.path), noTransactionDirective)
+		}
+	}
+
+	tx, err := conn.BeginTx(ctx, m.txOptions())
+	if err != nil {
+		return nil, fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	appliedMigrations, err := m.executeInTransaction(tx, ctx, pending)
+	if err != nil && (!m.options.savepoints || appliedMigrations == nil) {
+		return nil, err
+	}
+
+	checkErr := m.postMigrationCheck(tx, ctx)
+	if checkErr != nil {
+		return nil, errors.Join(err, checkErr)
+	}
+
+	// With savepoints the failed migrations have been rolled back on their
+	// own, so the others are committed and the failures reported after.
+	commitErr := tx.Commit()
+	if commitErr != nil {
+		return nil, fmt.Errorf("commit transaction: %w", commitErr)
+	}
+
+	return appliedMigrations, err
+}
+
+// executeInTransaction executes the pending migrations one after another in
+// tx and returns their names. Committing tx is up to the caller. With
+// WithSavepoints a failing migration is rolled back to its savepoint and the
+// remaining migrations are still executed; the names of the successful ones
+// are returned together with the joined errors of the failed ones.
+func (m *Migrator) executeInTransaction(tx *sql.Tx, ctx context.Context, pending []pendingMigration) ([]string, error) {
+	var (
+		appliedMigrations []string
+		errs              []error
+	)
+	for _, migration := range pending {
+		if !m.options.savepoints {
+			err := m.executeMigration(tx, ctx, migration)
+			if err != nil {
+				return nil, err
+			}
+			appliedMigrations = append(appliedMigrations, migration.name)
+			continue
+		}
+
+		migrationErr, err := m.executeInSavepoint(tx, ctx, migration, m.executeMigration)
+		if err != nil {
+			return nil, errors.Join(append(errs, err)...)
+		}
+		if migrationErr != nil {
+			errs = append(errs, migrationErr)
+			continue
+		}
+		appliedMigrations = append(appliedMigrations, migration.name)
+	}
+
+	return appliedMigrations, errors.Join(errs...)
+}
+
+// executeInSavepoint executes a migration with execute under a savepoint. A
+// failing migration is rolled back to the savepoint and returned as
+// migrationErr, leaving tx usable. Any other error means tx has to be rolled
+// back.
+func (m *Migrator) executeInSavepoint(tx *sql.Tx, ctx context.Context, migration pendingMigration, execute func(execer, context.Context, pendingMigration) error) (migrationErr error, err error) {
+	fileName := m.migrationFileName(migration.path)
+
+	_, err = tx.ExecContext(ctx, "SAVEPOINT "+savepointName)
+	if err != nil {
+		return nil, fmt.Errorf("create savepoint for migration %q: %w", fileName, err)
+	}
+
+	migrationErr = execute(tx, ctx, migration)
+	if migrationErr != nil {
+		_, err = tx.ExecContext(context.WithoutCancel(ctx), "ROLLBACK TO SAVEPOINT "+savepointName)
+		if err != nil {
+			return nil, errors.Join(migrationErr, fmt.Errorf("roll back to savepoint of migration %q: %w", fileName, err))
+		}
+		m.options.logger.WarnContext(ctx, "rolled back migration to its savepoint", "migration", migration.name, "error", migrationErr)
+	}
+
+	_, err = tx.ExecContext(ctx, "RELEASE SAVEPOINT "+savepointName)
+	if err != nil {
+		return nil, fmt.Errorf("release savepoint of migration %q: %w", fileName, err)
+	}
+
+	return migrationErr, nil
+}
+
+// applyMigrationInTransaction applies a single migration in its own
+// transaction.
+func (m *Migrator) applyMigrationInTransaction(conn *sql.Conn, ctx context.Context, migration pendingMigration) error {
+	tx, err := conn.BeginTx(ctx, m.txOptions())
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	err = m.executeMigration(tx, ctx, migration)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("commit migration %q: %w", m.migrationFileName(migration.path), err)
+	}
+
+	return nil
+}
@@ -0,0 +1,88 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Verify checks that every applied migration still matches its file. It
+// returns an error joining one error per problem found: a migration whose
+// file has been altered (ErrMigrationFileChanged), an applied migration whose
+// file is missing (ErrMigrationFileMissing) or a dirty migration
+// (ErrDirtyMigration). It only reads from the database, not even creating the
+// migrations table, so it can run with read-only access, e.g. as a health
+// check.
+func (m *Migrator) Verify() error {
+	timeoutCtx, cancel := context.WithTimeout(context.Background(), m.options.migrationTimeout)
+	defer cancel()
+
+	return m.VerifyContext(timeoutCtx)
+}
+
+// VerifyContext is like Verify but uses ctx for every database call instead
+// of the configured migration timeout.
+func (m *Migrator) VerifyContext(ctx context.Context) error {
+	conn, err := m.conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer m.closeConn(conn)
+
+	knownMigrations, err := m.readMigrationsKnownToDb(conn, ctx)
+	if err != nil {
+		return err
+	}
+
+	paths, err := m.migrationFiles()
+	if err != nil {
+		return err
+	}
+
+	excluded, err := m.excludedMigrations()
+	if err != nil {
+		return err
+	}
+
+	var (
+		errs   []error
+		onDisk = make(map[string]bool, len(paths))
+	)
+	for _, migrationPath := range paths {
+		migration, ok := m.findMigration(knownMigrations, migrationPath)
+		if !ok {
+			continue
+		}
+		onDisk[migration.MigrationName] = true
+		if !migration.IsApplied {
+			continue
+		}
+
+		readBytes, err := m.readMigration(migrationPath)
+		if err != nil {
+			return fmt.Errorf("read migration file %q: %w", m.migrationFileName(migrationPath), err)
+		}
+
+		currentHash, unchanged, err := m.hashMatches(readBytes, migration.MigrationHash)
+		if err != nil {
+			return fmt.Errorf("hash migration %q: %w", m.migrationFileName(migrationPath), err)
+		}
+		if !unchanged {
+			errs = append(errs, alteredMigrationError(migration, currentHash))
+		}
+	}
+
+	for _, migration := range knownMigrations.rows {
+		if excluded[migration.MigrationName] {
+			continue
+		}
+		if migration.IsDirty {
+			errs = append(errs, fmt.Errorf("migration %q: %w", migration.MigrationName, ErrDirtyMigration))
+		}
+		if migration.IsApplied && !onDisk[migration.MigrationName] {
+			errs = append(errs, fmt.Errorf("migration %q: %w", migration.MigrationName, ErrMigrationFileMissing))
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,29 @@
+package migrate
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// parseVersion returns the version of a migration, which is the integer its
+// file name starts with, e.g. 2 for "2_add_users.sql" or 20240101120000 for
+// "20240101120000_add_users.sql". Only the ASCII digits 0 to 9 count, as
+// strconv cannot parse other Unicode digits. Versions are parsed as int64 so
+// timestamp versions compare correctly.
+func parseVersion(name string) (int64, error) {
+	digits := name
+	if end := strings.IndexFunc(name, func(r rune) bool { return r < '0' || r > '9' }); end >= 0 {
+		digits = name[:end]
+	}
+	if digits == "" {
+		return 0, fmt.Errorf("file name %q does not start with a numeric version", name)
+	}
+
+	version, err := strconv.ParseInt(digits, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("parse version of %q: %w", name, err)
+	}
+
+	return version, nil
+}